@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aherve/eChess/goapp/lichess"
+)
+
+// Games against opponents matching any of these rules are aborted right away
+type AutoAbortRules struct {
+	Provisional bool                `json:"provisional"` // abort opponents with a provisional rating on the game speed
+	SkipSpeeds  []lichess.GameSpeed `json:"skipSpeeds"`  // never abort these speeds, where opponents are harder to find
+	// abort accounts younger than this. 0 means no minimum
+	MinAccountAgeDays int `json:"minAccountAgeDays"`
+	// abort opponents whose rating differs from ours by more than this. 0 means no maximum
+	MaxRatingGap int  `json:"maxRatingGap"`
+	Closed       bool `json:"closed"` // abort closed or TOS-flagged accounts
+	// usernames, compared case-insensitively
+	Blocklist []string `json:"blocklist"` // always abort these opponents
+	Allowlist []string `json:"allowlist"` // never abort these opponents, whatever the other rules
+}
+
+// Too many cheaters among provisional players. Aborting on classical is a bit greedy though
+func defaultAutoAbortRules() AutoAbortRules {
+	return AutoAbortRules{
+		Provisional: true,
+		SkipSpeeds:  []lichess.GameSpeed{lichess.Classical},
+	}
+}
+
+// AbortDecision records why the game against an opponent was aborted or kept
+type AbortDecision struct {
+	GameID   string
+	Opponent string
+	Abort    bool
+	Reason   string
+}
+
+func (d AbortDecision) String() string {
+	if d.Abort {
+		return fmt.Sprintf("Aborted against %s: %s", d.Opponent, d.Reason)
+	}
+	return fmt.Sprintf("Playing %s: %s", d.Opponent, d.Reason)
+}
+
+// AbortReason returns why the game should be aborted, or an empty string if it should be played. myRating is our rating on the game speed
+func (r AutoAbortRules) AbortReason(opponent *lichess.PlayerProfile, speed lichess.GameSpeed, myRating int, now time.Time) string {
+	if containsUsername(r.Allowlist, opponent) {
+		return ""
+	}
+	if containsUsername(r.Blocklist, opponent) {
+		return "blocklisted"
+	}
+	if slices.Contains(r.SkipSpeeds, speed) {
+		return ""
+	}
+	if r.Closed && opponent.Disabled {
+		return "closed account"
+	}
+	if r.Closed && opponent.TosViolation {
+		return "flagged for violating the terms of service"
+	}
+	if r.Provisional && opponent.IsProvisional(speed) {
+		return fmt.Sprintf("provisional rating on %s", speed)
+	}
+	if r.MinAccountAgeDays > 0 && opponent.CreatedAt > 0 {
+		age := now.Sub(opponent.Created())
+		if age < time.Duration(r.MinAccountAgeDays)*24*time.Hour {
+			return fmt.Sprintf("account created %d days ago", int(age.Hours()/24))
+		}
+	}
+	if perf, ok := opponent.Perfs.Perf(speed); r.MaxRatingGap > 0 && ok && myRating > 0 {
+		if gap := perf.Rating - myRating; gap > r.MaxRatingGap || -gap > r.MaxRatingGap {
+			return fmt.Sprintf("rated %d, %+d from us", perf.Rating, gap)
+		}
+	}
+	return ""
+}
+
+func containsUsername(usernames []string, player *lichess.PlayerProfile) bool {
+	return slices.ContainsFunc(usernames, func(username string) bool {
+		return strings.EqualFold(username, player.ID) || strings.EqualFold(username, player.Username)
+	})
+}
+
+// applyAbortRules checks the opponent of the current game against the configured rules, and aborts the game if any of them matches. myRating is 0 when unknown
+func applyAbortRules(state *MainState, player *lichess.PlayerProfile, myRating int) {
+	game := state.Game()
+
+	decision := AbortDecision{GameID: game.FullID(), Opponent: player.Username, Reason: "no abort rule matched"}
+	if reason := state.Config().AutoAbort.AbortReason(player, game.Speed(), myRating, time.Now()); reason != "" {
+		decision.Abort = true
+		decision.Reason = reason
+	}
+	log.Println(decision)
+
+	if decision.Abort {
+		state.Backend().Abort(state.Context(), decision.GameID)
+	}
+	state.UIState().SetAbortDecision(&decision)
+	state.UIState().Input <- AbortDecided
+}
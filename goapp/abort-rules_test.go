@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aherve/eChess/goapp/lichess"
+)
+
+func TestAbortReason(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	opponent := &lichess.PlayerProfile{
+		ID:        "opponent",
+		Username:  "Opponent",
+		CreatedAt: now.AddDate(-1, 0, 0).UnixMilli(),
+		Perfs: lichess.PlayerPerfs{
+			Blitz:     lichess.PlayerPerf{Rating: 1500, Prov: true},
+			Classical: lichess.PlayerPerf{Rating: 1800, Prov: true},
+		},
+	}
+
+	if reason := (AutoAbortRules{}).AbortReason(opponent, lichess.Blitz, 1500, now); reason != "" {
+		t.Errorf("expected no abort without rules, got %s", reason)
+	}
+
+	// provisional on any speed, except the skipped ones
+	if reason := defaultAutoAbortRules().AbortReason(opponent, lichess.Blitz, 1500, now); reason == "" {
+		t.Errorf("expected a provisional blitz opponent to be aborted")
+	}
+	if reason := defaultAutoAbortRules().AbortReason(opponent, lichess.Classical, 1500, now); reason != "" {
+		t.Errorf("expected classical games to be played, got %s", reason)
+	}
+
+	rules := AutoAbortRules{MinAccountAgeDays: 30, MaxRatingGap: 200, Closed: true}
+	if reason := rules.AbortReason(opponent, lichess.Blitz, 1400, now); reason != "" {
+		t.Errorf("expected the opponent to pass the rules, got %s", reason)
+	}
+	if reason := rules.AbortReason(opponent, lichess.Blitz, 1200, now); reason == "" {
+		t.Errorf("expected a 300 points gap to be aborted")
+	}
+
+	young := *opponent
+	young.CreatedAt = now.AddDate(0, 0, -3).UnixMilli()
+	if reason := rules.AbortReason(&young, lichess.Blitz, 1500, now); reason == "" {
+		t.Errorf("expected a 3 days old account to be aborted")
+	}
+
+	flagged := *opponent
+	flagged.TosViolation = true
+	if reason := rules.AbortReason(&flagged, lichess.Blitz, 1500, now); reason == "" {
+		t.Errorf("expected a TOS-flagged account to be aborted")
+	}
+
+	// lists are case-insensitive, and the allowlist wins over every other rule
+	rules = AutoAbortRules{Provisional: true, Blocklist: []string{"OPPONENT"}}
+	if reason := rules.AbortReason(opponent, lichess.Classical, 1500, now); reason != "blocklisted" {
+		t.Errorf("expected a blocklisted opponent to be aborted, got %s", reason)
+	}
+	rules.Allowlist = []string{"opponent"}
+	if reason := rules.AbortReason(opponent, lichess.Blitz, 1500, now); reason != "" {
+		t.Errorf("expected an allowlisted opponent to be played, got %s", reason)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+// ArbiterPeriod is how often the arbiter looks at the board. Humans don't lift and put back a piece faster
+const ArbiterPeriod = 100 * time.Millisecond
+
+type IncidentKind string
+
+const (
+	IllegalMoveIncident IncidentKind = "illegal move"
+	TouchMoveIncident   IncidentKind = "touch move"
+	OutOfTurnIncident   IncidentKind = "out of turn"
+)
+
+// Incident is an irregularity noticed by the arbiter
+type Incident struct {
+	At      time.Time
+	Ply     int // moves played before the incident
+	Kind    IncidentKind
+	Color   chess.Color // the player at fault
+	Squares []chess.Square
+	Move    string // for illegal moves
+}
+
+func (i Incident) String() string {
+	number := fmt.Sprintf("%d.", i.Ply/2+1)
+	if i.Ply%2 == 1 {
+		number = fmt.Sprintf("%d...", i.Ply/2+1)
+	}
+
+	var description string
+	switch i.Kind {
+	case IllegalMoveIncident:
+		description = "illegal move " + i.Move
+	case TouchMoveIncident:
+		description = fmt.Sprintf("touched the piece on %s without moving it", squareNames(i.Squares))
+	case OutOfTurnIncident:
+		description = fmt.Sprintf("moved out of turn to %s", squareNames(i.Squares))
+	}
+	return fmt.Sprintf("%s %s %s", number, colorName(i.Color), description)
+}
+
+func squareNames(squares []chess.Square) string {
+	names := []string{}
+	for _, square := range squares {
+		names = append(names, square.String())
+	}
+	return strings.Join(names, ", ")
+}
+
+func colorName(color chess.Color) string {
+	if color == chess.White {
+		return "White"
+	}
+	return "Black"
+}
+
+// Arbiter follows an over the board game from the board state alone, and keeps a log of the incidents
+type Arbiter struct {
+	plies     int
+	synced    bool                  // the board matched the game once, so the pieces are set up
+	lifted    map[chess.Square]bool // pieces of the side to move lifted during this turn
+	outOfTurn bool
+	illegal   string
+	incidents []Incident
+
+	mu sync.Mutex
+}
+
+func NewArbiter() *Arbiter {
+	return &Arbiter{lifted: map[chess.Square]bool{}}
+}
+
+func (a *Arbiter) Incidents() []Incident {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Incident{}, a.incidents...)
+}
+
+// Observe compares the board with the position after the given number of moves, and returns the new incidents. illegal is the illegal move shown on the board, if any
+func (a *Arbiter) Observe(board BoardState, position *chess.Position, plies int, illegal string, now time.Time) []Incident {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if plies != a.plies {
+		a.plies = plies
+		a.lifted = map[chess.Square]bool{}
+		a.outOfTurn = false
+	}
+
+	turn := position.Turn()
+	diffs := 0
+	placed := []chess.Square{}
+	for i := range 8 {
+		for j := range 8 {
+			square := chess.NewSquare(chess.File(i), chess.Rank(j))
+			positionColor := position.Board().Piece(square).Color()
+			boardColor := board[i][j]
+			if positionColor == boardColor {
+				continue
+			}
+			diffs++
+			if positionColor == turn && boardColor == chess.NoColor {
+				a.lifted[square] = true
+			}
+			// the side to move never makes a piece of the other side appear
+			if boardColor == turn.Other() {
+				placed = append(placed, square)
+			}
+		}
+	}
+
+	if diffs == 0 && !a.synced {
+		a.synced = true
+		a.lifted = map[chess.Square]bool{}
+	}
+	// pieces are being set up
+	if !a.synced {
+		return nil
+	}
+
+	incident := func(kind IncidentKind, color chess.Color, squares []chess.Square) Incident {
+		return Incident{At: now, Ply: plies, Kind: kind, Color: color, Squares: squares}
+	}
+	incidents := []Incident{}
+
+	if len(placed) > 0 && !a.outOfTurn {
+		incidents = append(incidents, incident(OutOfTurnIncident, turn.Other(), placed))
+	}
+	a.outOfTurn = len(placed) > 0
+
+	// a piece was put back where it was lifted from
+	if diffs == 0 {
+		for square := range a.lifted {
+			incidents = append(incidents, incident(TouchMoveIncident, turn, []chess.Square{square}))
+		}
+		a.lifted = map[chess.Square]bool{}
+	}
+
+	if illegal != "" && illegal != a.illegal {
+		i := incident(IllegalMoveIncident, turn, moveSquares(illegal))
+		i.Move = illegal
+		incidents = append(incidents, i)
+	}
+	a.illegal = illegal
+
+	a.incidents = append(a.incidents, incidents...)
+	return incidents
+}
+
+// moveSquares returns the squares of a move in UCI notation
+func moveSquares(move string) []chess.Square {
+	squares := []chess.Square{}
+	for _, name := range []string{move[0:2], move[2:4]} {
+		if square, ok := parseSquare(name); ok {
+			squares = append(squares, square)
+		}
+	}
+	return squares
+}
+
+func parseSquare(name string) (chess.Square, bool) {
+	if len(name) != 2 || name[0] < 'a' || name[0] > 'h' || name[1] < '1' || name[1] > '8' {
+		return chess.NoSquare, false
+	}
+	return chess.NewSquare(chess.File(name[0]-'a'), chess.Rank(name[1]-'1')), true
+}
+
+// watchBoard lets the arbiter look at the board until done is closed. Incidents are shown in the UI and with the LEDs
+func watchBoard(done <-chan struct{}, state *MainState, arbiter *Arbiter) {
+	ticker := time.NewTicker(ArbiterPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			// the board is being set up, or brought back to the game
+			if state.AwaitingSync() {
+				continue
+			}
+			game := state.Game()
+			for _, incident := range arbiter.Observe(state.Board().State(), game.ChessGame().Position(), len(game.Moves()), state.IllegalMove(), time.Now()) {
+				log.Printf("Arbiter: %s", incident)
+				state.UIState().SetLastIncident(&incident)
+				state.UIState().Input <- ArbiterIncident
+				go state.PlayIncidentSequence(incident.Squares)
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+// boardOf returns the board state of a position, as the sensors would report it
+func boardOf(position *chess.Position) BoardState {
+	board := BoardState{}
+	for i := range 8 {
+		for j := range 8 {
+			board[i][j] = position.Board().Piece(chess.NewSquare(chess.File(i), chess.Rank(j))).Color()
+		}
+	}
+	return board
+}
+
+func setSquare(board BoardState, square chess.Square, color chess.Color) BoardState {
+	board[square.File()][square.Rank()] = color
+	return board
+}
+
+func TestArbiterTouchMove(t *testing.T) {
+	a := NewArbiter()
+	now := time.Now()
+	position := chess.StartingPosition()
+	board := boardOf(position)
+
+	a.Observe(board, position, 0, "", now)
+	if incidents := a.Observe(setSquare(board, chess.G1, chess.NoColor), position, 0, "", now); len(incidents) != 0 {
+		t.Fatalf("lifting a piece is not an incident, got %v", incidents)
+	}
+	incidents := a.Observe(board, position, 0, "", now)
+	if len(incidents) != 1 || incidents[0].Kind != TouchMoveIncident || incidents[0].Color != chess.White || incidents[0].Squares[0] != chess.G1 {
+		t.Fatalf("expected a touch move on g1, got %v", incidents)
+	}
+	if incidents[0].String() != "1. White touched the piece on g1 without moving it" {
+		t.Errorf("unexpected description %q", incidents[0].String())
+	}
+
+	// the piece was moved: the lifted piece is forgotten with the new position
+	a.Observe(setSquare(board, chess.E2, chess.NoColor), position, 0, "", now)
+	game := chess.NewGame()
+	if err := game.MoveStr("e4"); err != nil {
+		t.Fatal(err)
+	}
+	if incidents := a.Observe(boardOf(game.Position()), game.Position(), 1, "", now); len(incidents) != 0 {
+		t.Errorf("a played move is not an incident, got %v", incidents)
+	}
+	if len(a.Incidents()) != 1 {
+		t.Errorf("expected 1 incident in the log, got %v", a.Incidents())
+	}
+}
+
+func TestArbiterOutOfTurn(t *testing.T) {
+	a := NewArbiter()
+	now := time.Now()
+	position := chess.StartingPosition()
+	board := boardOf(position)
+	a.Observe(board, position, 0, "", now)
+
+	// black plays e7e5 before white moved
+	board = setSquare(setSquare(board, chess.E7, chess.NoColor), chess.E5, chess.Black)
+	incidents := a.Observe(board, position, 0, "", now)
+	if len(incidents) != 1 || incidents[0].Kind != OutOfTurnIncident || incidents[0].Color != chess.Black || incidents[0].Squares[0] != chess.E5 {
+		t.Fatalf("expected black out of turn on e5, got %v", incidents)
+	}
+	// reported once while the piece stays there
+	if incidents := a.Observe(board, position, 0, "", now); len(incidents) != 0 {
+		t.Errorf("expected no new incident, got %v", incidents)
+	}
+}
+
+func TestArbiterIllegalMove(t *testing.T) {
+	a := NewArbiter()
+	now := time.Now()
+	position := chess.StartingPosition()
+	a.Observe(boardOf(position), position, 0, "", now)
+
+	board := setSquare(setSquare(boardOf(position), chess.E2, chess.NoColor), chess.E5, chess.White)
+	incidents := a.Observe(board, position, 0, "e2e5", now)
+	if len(incidents) != 1 || incidents[0].Kind != IllegalMoveIncident || incidents[0].Move != "e2e5" || len(incidents[0].Squares) != 2 {
+		t.Fatalf("expected an illegal move e2e5, got %v", incidents)
+	}
+	if incidents := a.Observe(board, position, 0, "e2e5", now); len(incidents) != 0 {
+		t.Errorf("the illegal move is reported once, got %v", incidents)
+	}
+}
+
+func TestArbiterWaitsForSetup(t *testing.T) {
+	a := NewArbiter()
+	now := time.Now()
+	position := chess.StartingPosition()
+
+	// black pieces are placed on an empty board
+	board := BoardState{}
+	for _, square := range []chess.Square{chess.A8, chess.B8, chess.E7} {
+		board = setSquare(board, square, chess.Black)
+		if incidents := a.Observe(board, position, 0, "", now); len(incidents) != 0 {
+			t.Fatalf("no incident while the pieces are set up, got %v", incidents)
+		}
+	}
+	if incidents := a.Observe(boardOf(position), position, 0, "", now); len(incidents) != 0 {
+		t.Errorf("no touch move once the pieces are set up, got %v", incidents)
+	}
+}
@@ -0,0 +1,149 @@
+package archive
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IndexFile lists the archived games, next to their PGN files
+const IndexFile = "index.json"
+
+// Entry describes an archived game
+type Entry struct {
+	GameID   string    `json:"gameId"`
+	Date     time.Time `json:"date"`
+	Opponent string    `json:"opponent"`
+	Color    string    `json:"color"`   // "white" or "black"
+	Speed    string    `json:"speed"`   // bullet, blitz, rapid...
+	Outcome  string    `json:"outcome"` // "won", "lost", "drawn" or "aborted"
+	Result   string    `json:"result"`  // PGN result: 1-0, 0-1 or 1/2-1/2
+	Reason   string    `json:"reason"`
+	Rated    bool      `json:"rated"`
+	File     string    `json:"file"` // PGN file, relative to the archive directory
+}
+
+// Query filters archived games. Zero fields match every game
+type Query struct {
+	Opponent string // case-insensitive substring of the opponent username
+	From     time.Time
+	To       time.Time // the whole day of To is included
+	Speed    string
+	Outcome  string
+}
+
+func (q Query) Matches(e Entry) bool {
+	if q.Opponent != "" && !strings.Contains(strings.ToLower(e.Opponent), strings.ToLower(q.Opponent)) {
+		return false
+	}
+	if !q.From.IsZero() && e.Date.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() {
+		year, month, day := q.To.Date()
+		if !e.Date.Before(time.Date(year, month, day+1, 0, 0, 0, 0, q.To.Location())) {
+			return false
+		}
+	}
+	if q.Speed != "" && q.Speed != e.Speed {
+		return false
+	}
+	if q.Outcome != "" && q.Outcome != e.Outcome {
+		return false
+	}
+	return true
+}
+
+// Archive stores finished games as PGN files in a directory, with an index to list and search them
+type Archive struct {
+	dir     string
+	entries []Entry
+
+	mu sync.RWMutex
+}
+
+// Open loads the archive index from dir, creating the directory when needed
+func Open(dir string) (*Archive, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	a := &Archive{dir: dir, entries: []Entry{}}
+	data, err := os.ReadFile(filepath.Join(dir, IndexFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &a.entries); err != nil {
+		return nil, fmt.Errorf("error reading archive index: %w", err)
+	}
+	return a, nil
+}
+
+func (a *Archive) Dir() string {
+	return a.dir
+}
+
+// Add writes the PGN of a game and records it in the index. Adding a game twice replaces it
+func (a *Archive) Add(entry Entry, pgn string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entry.File = fmt.Sprintf("%s-%s.pgn", entry.Date.Format("2006-01-02"), entry.GameID)
+	if err := os.WriteFile(filepath.Join(a.dir, entry.File), []byte(pgn), 0644); err != nil {
+		return err
+	}
+
+	a.entries = slices.DeleteFunc(a.entries, func(e Entry) bool {
+		return e.GameID == entry.GameID
+	})
+	a.entries = append(a.entries, entry)
+	return a.save()
+}
+
+func (a *Archive) save() error {
+	data, err := json.MarshalIndent(a.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(a.dir, IndexFile), data, 0644)
+}
+
+// List returns every archived game, most recent first
+func (a *Archive) List() []Entry {
+	return a.Search(Query{})
+}
+
+// Search returns the archived games matching the query, most recent first
+func (a *Archive) Search(q Query) []Entry {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	found := []Entry{}
+	for _, e := range a.entries {
+		if q.Matches(e) {
+			found = append(found, e)
+		}
+	}
+	slices.SortFunc(found, func(x, y Entry) int {
+		return y.Date.Compare(x.Date)
+	})
+	return found
+}
+
+// PGN reads the PGN file of an archived game
+func (a *Archive) PGN(e Entry) (string, error) {
+	data, err := os.ReadFile(filepath.Join(a.dir, e.File))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
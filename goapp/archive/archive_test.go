@@ -0,0 +1,61 @@
+package archive
+
+import (
+	"testing"
+	"time"
+)
+
+func TestArchive(t *testing.T) {
+	dir := t.TempDir()
+	a, err := Open(dir)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+
+	day := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	games := []Entry{
+		{GameID: "game1", Date: day, Opponent: "Magnus", Speed: "blitz", Outcome: "lost", Result: "0-1"},
+		{GameID: "game2", Date: day.AddDate(0, 0, 1), Opponent: "Hikaru", Speed: "rapid", Outcome: "won", Result: "1-0"},
+		{GameID: "game3", Date: day.AddDate(0, 0, 2), Opponent: "magnus", Speed: "rapid", Outcome: "drawn", Result: "1/2-1/2"},
+	}
+	for _, game := range games {
+		if err := a.Add(game, "[Event \"test\"]\n\n1. e4 *\n"); err != nil {
+			t.Fatalf("failed to add game: %v", err)
+		}
+	}
+
+	// the index survives reopening the archive
+	a, err = Open(dir)
+	if err != nil {
+		t.Fatalf("failed to reopen archive: %v", err)
+	}
+	list := a.List()
+	if len(list) != 3 || list[0].GameID != "game3" || list[2].GameID != "game1" {
+		t.Fatalf("expected 3 games, most recent first, got %+v", list)
+	}
+	if pgn, err := a.PGN(list[0]); err != nil || pgn == "" {
+		t.Errorf("expected to read the PGN back, got %q, %v", pgn, err)
+	}
+
+	if found := a.Search(Query{Opponent: "MAG"}); len(found) != 2 {
+		t.Errorf("expected 2 games against magnus, got %+v", found)
+	}
+	if found := a.Search(Query{Speed: "rapid", Outcome: "won"}); len(found) != 1 || found[0].GameID != "game2" {
+		t.Errorf("expected the rapid win, got %+v", found)
+	}
+	if found := a.Search(Query{From: day.AddDate(0, 0, 1), To: day.AddDate(0, 0, 1)}); len(found) != 1 || found[0].GameID != "game2" {
+		t.Errorf("expected the game of the second day, got %+v", found)
+	}
+	// games played later on the last day are found
+	if found := a.Search(Query{To: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)}); len(found) != 1 || found[0].GameID != "game1" {
+		t.Errorf("expected the game of the first day, got %+v", found)
+	}
+
+	// archiving a game again replaces it
+	if err := a.Add(games[0], "1. d4 *\n"); err != nil {
+		t.Fatalf("failed to add game: %v", err)
+	}
+	if len(a.List()) != 3 {
+		t.Errorf("expected the game to be replaced, got %+v", a.List())
+	}
+}
@@ -157,24 +157,22 @@ func (b *Board) Connect(c chan bool) {
 	}
 }
 
+// IsStartingPosition tells whether the pieces are set up to start a game. Chess960 positions occupy the same squares as the standard one
 func (b *Board) IsStartingPosition() bool {
-	startingPosition := [8][8]chess.Color{
-		{chess.White, chess.White, chess.NoColor, chess.NoColor, chess.NoColor, chess.NoColor, chess.Black, chess.Black},
-		{chess.White, chess.White, chess.NoColor, chess.NoColor, chess.NoColor, chess.NoColor, chess.Black, chess.Black},
-		{chess.White, chess.White, chess.NoColor, chess.NoColor, chess.NoColor, chess.NoColor, chess.Black, chess.Black},
-		{chess.White, chess.White, chess.NoColor, chess.NoColor, chess.NoColor, chess.NoColor, chess.Black, chess.Black},
-		{chess.White, chess.White, chess.NoColor, chess.NoColor, chess.NoColor, chess.NoColor, chess.Black, chess.Black},
-		{chess.White, chess.White, chess.NoColor, chess.NoColor, chess.NoColor, chess.NoColor, chess.Black, chess.Black},
-		{chess.White, chess.White, chess.NoColor, chess.NoColor, chess.NoColor, chess.NoColor, chess.Black, chess.Black},
-		{chess.White, chess.White, chess.NoColor, chess.NoColor, chess.NoColor, chess.NoColor, chess.Black, chess.Black},
-	}
+	return b.Matches(chess.StartingPosition())
+}
+
+// Matches tells whether the board squares are occupied by pieces of the same colors as in the position
+func (b *Board) Matches(position *chess.Position) bool {
+	board := position.Board()
 
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
 	for i := range b.state {
 		for j := range b.state[i] {
-			if b.state[i][j] != startingPosition[i][j] {
+			square := chess.NewSquare(chess.File(i), chess.Rank(j))
+			if b.state[i][j] != board.Piece(square).Color() {
 				return false
 			}
 		}
@@ -186,6 +184,11 @@ func (b *Board) sendLEDCommand(litSquares map[int8]bool) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	// no board connected, e.g. in debug mode
+	if b.port == nil {
+		return
+	}
+
 	command := make([]byte, len(litSquares)+2)
 	command[0] = 0xFE
 	command[len(command)-1] = 0xFF
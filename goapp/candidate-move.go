@@ -1,20 +1,39 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"sync"
 	"time"
-
-	"github.com/aherve/eChess/goapp/lichess"
 )
 
 type CandidateMove struct {
 	move           string
 	issuedAt       time.Time
 	lastMovePlayed string
+	retrying       bool // the move failed to reach the backend, and is sent again until it does
 	mu             sync.RWMutex
 }
 
+// Moves that failed to reach the backend are sent again after this delay, doubled on each attempt
+const (
+	moveRetryDelay    = 250 * time.Millisecond
+	maxMoveRetryDelay = 5 * time.Second
+)
+
+// retryDelay returns how long to wait before the given retry (starting at 0)
+func retryDelay(attempt int) time.Duration {
+	delay := moveRetryDelay
+	for range attempt {
+		delay *= 2
+		if delay >= maxMoveRetryDelay {
+			return maxMoveRetryDelay
+		}
+	}
+	return delay
+}
+
 func NewCandidateMove() *CandidateMove {
 	return &CandidateMove{
 		move:     "",
@@ -43,17 +62,18 @@ func (cm *CandidateMove) Reset() {
 	cm.move = ""
 	cm.issuedAt = time.Now()
 	cm.lastMovePlayed = ""
+	cm.retrying = false
 }
 
 /*
 * Will schedule a move and play it later, provided a new move hasn't been planned in between.
 This method can be called on empty string to cancel a previously planned move
 */
-func (cm *CandidateMove) PlayWithDelay(gameID, move string) {
-	cm.recursivePlayWithDelay(gameID, move, true)
+func (cm *CandidateMove) PlayWithDelay(ctx context.Context, backend GameBackend, gameID, move string) {
+	cm.recursivePlayWithDelay(ctx, backend, gameID, move, true)
 }
 
-func (cm *CandidateMove) recursivePlayWithDelay(gameID, move string, shouldSchedule bool) {
+func (cm *CandidateMove) recursivePlayWithDelay(ctx context.Context, backend GameBackend, gameID, move string, shouldSchedule bool) {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
@@ -68,12 +88,13 @@ func (cm *CandidateMove) recursivePlayWithDelay(gameID, move string, shouldSched
 	if move != existing && shouldSchedule {
 		cm.move = move
 		cm.issuedAt = time.Now()
+		cm.retrying = false
 
 		// schedule a new call (only if move isn't empty)
 		if move != "" {
 			go func(g, m string) {
 				time.Sleep(PlayDelay + time.Millisecond)
-				cm.recursivePlayWithDelay(g, m, false)
+				cm.recursivePlayWithDelay(ctx, backend, g, m, false)
 			}(gameID, move)
 		}
 		return
@@ -86,6 +107,11 @@ func (cm *CandidateMove) recursivePlayWithDelay(gameID, move string, shouldSched
 
 	// move == existing
 
+	// the move is already being sent again
+	if cm.retrying {
+		return
+	}
+
 	// is it too soon ?
 	if time.Since(cm.issuedAt) < PlayDelay {
 		// same move is already scheduled for later. Don't pile up
@@ -98,14 +124,47 @@ func (cm *CandidateMove) recursivePlayWithDelay(gameID, move string, shouldSched
 	}
 
 	// move is non-empty, and it's time => play it!
-	err := lichess.PlayMove(gameID, move)
-	if err != nil {
-		// Error can happen becaus a move that once was valid could now be invalid
-		log.Printf("WARNING: failed to play move: %+v. Clearing state", err)
+	cm.play(ctx, backend, gameID, move, 0)
+}
+
+// play sends the move to the backend. Moves that don't reach it are sent again, as no board update may come to schedule them: the player is done moving. Must be called with the lock held
+func (cm *CandidateMove) play(ctx context.Context, backend GameBackend, gameID, move string, attempt int) {
+	err := backend.PlayMove(ctx, gameID, move)
+
+	if err != nil && !errors.Is(err, ErrMoveRejected) && ctx.Err() == nil {
+		delay := retryDelay(attempt)
+		log.Printf("WARNING: failed to play move %s: %v. Retrying in %s", move, err, delay)
+		cm.retrying = true
+		go cm.retryPlay(ctx, backend, gameID, move, attempt+1, delay)
+		return
 	}
+
 	// reset our state
 	cm.move = ""
 	cm.issuedAt = time.Now()
+	cm.retrying = false
+
+	if err != nil {
+		// Error can happen becaus a move that once was valid could now be invalid
+		log.Printf("WARNING: failed to play move: %+v. Clearing state", err)
+	}
 	cm.lastMovePlayed = move
+}
 
+// retryPlay sends the move again after the delay, unless the game moved on in between
+func (cm *CandidateMove) retryPlay(ctx context.Context, backend GameBackend, gameID, move string, attempt int, delay time.Duration) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(delay):
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	// another move was made, or the game was left
+	if cm.move != move || !cm.retrying {
+		return
+	}
+	cm.play(ctx, backend, gameID, move, attempt)
 }
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingBackend records the moves played, and fails them with err
+type recordingBackend struct {
+	LichessBackend
+	err   error
+	moves []string
+	mu    sync.Mutex
+}
+
+func (b *recordingBackend) PlayMove(ctx context.Context, gameID, move string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.moves = append(b.moves, move)
+	return b.err
+}
+
+func (b *recordingBackend) SetErr(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.err = err
+}
+
+func (b *recordingBackend) Played() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string{}, b.moves...)
+}
+
+func TestCandidateMove(t *testing.T) {
+	ctx := context.Background()
+	backend := &recordingBackend{}
+	cm := NewCandidateMove()
+
+	// the piece slides through e3 before reaching e4: only the stable move is played
+	cm.PlayWithDelay(ctx, backend, "game", "e2e3")
+	cm.PlayWithDelay(ctx, backend, "game", "e2e4")
+	time.Sleep(2 * PlayDelay)
+	if played := backend.Played(); len(played) != 1 || played[0] != "e2e4" {
+		t.Errorf("expected e2e4 to be played once, got %v", played)
+	}
+
+	// the same move is not played twice
+	cm.PlayWithDelay(ctx, backend, "game", "e2e4")
+	time.Sleep(2 * PlayDelay)
+	if played := backend.Played(); len(played) != 1 {
+		t.Errorf("expected e2e4 not to be played again, got %v", played)
+	}
+
+	// a move that never reached the backend is sent again, without waiting for a board update
+	cm.Reset()
+	backend.SetErr(errors.New("network error"))
+	cm.PlayWithDelay(ctx, backend, "game", "d2d4")
+	time.Sleep(2 * PlayDelay)
+	backend.SetErr(nil)
+	time.Sleep(4 * moveRetryDelay)
+	if played := backend.Played(); len(played) < 3 || played[len(played)-1] != "d2d4" {
+		t.Errorf("expected d2d4 to be sent again after a failure, got %v", played)
+	}
+	sent := len(backend.Played())
+	time.Sleep(4 * moveRetryDelay)
+	if played := backend.Played(); len(played) != sent {
+		t.Errorf("expected d2d4 not to be sent once it succeeded, got %v", played)
+	}
+
+	// a rejected move is not sent again
+	cm.Reset()
+	backend.SetErr(ErrMoveRejected)
+	cm.PlayWithDelay(ctx, backend, "game", "e7e5")
+	time.Sleep(2 * PlayDelay)
+	rejected := len(backend.Played())
+	time.Sleep(4 * moveRetryDelay)
+	if len(backend.Played()) != rejected {
+		t.Errorf("expected the rejected move not to be sent again, got %v", backend.Played())
+	}
+
+	// retries stop when the game is left
+	cm.Reset()
+	backend.SetErr(errors.New("network error"))
+	cm.PlayWithDelay(ctx, backend, "game", "g1f3")
+	time.Sleep(2 * PlayDelay)
+	cm.Reset()
+	played := len(backend.Played())
+	time.Sleep(4 * moveRetryDelay)
+	if len(backend.Played()) != played {
+		t.Errorf("expected no retry after leaving the game, got %v", backend.Played())
+	}
+}
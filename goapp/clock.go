@@ -0,0 +1,103 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+type IncrementMode string
+
+const (
+	// FischerIncrement adds the increment after each move
+	FischerIncrement IncrementMode = "fischer"
+	// BronsteinIncrement gives back the time spent on the move, up to the increment
+	BronsteinIncrement IncrementMode = "bronstein"
+)
+
+type ClockSettings struct {
+	Initial   time.Duration
+	Increment time.Duration
+	Mode      IncrementMode
+}
+
+// Clock is a chess clock for games played without lichess. Only the side to move is running
+type Clock struct {
+	settings      ClockSettings
+	white         time.Duration
+	black         time.Duration
+	turn          chess.Color
+	turnStartedAt time.Time
+
+	mu sync.RWMutex
+}
+
+// NewClock starts white's clock at the given time
+func NewClock(settings ClockSettings, now time.Time) *Clock {
+	return &Clock{
+		settings:      settings,
+		white:         settings.Initial,
+		black:         settings.Initial,
+		turn:          chess.White,
+		turnStartedAt: now,
+	}
+}
+
+func (c *Clock) Settings() ClockSettings {
+	return c.settings
+}
+
+func (c *Clock) Turn() chess.Color {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.turn
+}
+
+// Remaining returns the time left to the given side
+func (c *Clock) Remaining(color chess.Color, now time.Time) time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	remaining := c.black
+	if color == chess.White {
+		remaining = c.white
+	}
+	if color == c.turn {
+		remaining -= now.Sub(c.turnStartedAt)
+	}
+	return max(remaining, 0)
+}
+
+// Press ends the turn of the side to move, starts the clock of the other side, and returns the time spent on the move
+func (c *Clock) Press(now time.Time) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	spent := now.Sub(c.turnStartedAt)
+	bonus := c.settings.Increment
+	if c.settings.Mode == BronsteinIncrement {
+		bonus = min(spent, c.settings.Increment)
+	}
+
+	if c.turn == chess.White {
+		c.white += bonus - spent
+		c.turn = chess.Black
+	} else {
+		c.black += bonus - spent
+		c.turn = chess.White
+	}
+	c.turnStartedAt = now
+	return spent
+}
+
+// Flagged returns the side to move if it ran out of time
+func (c *Clock) Flagged(now time.Time) (chess.Color, bool) {
+	turn := c.Turn()
+	return turn, c.Remaining(turn, now) <= 0
+}
+
+// Millis returns the time left to white and black, in milliseconds as lichess reports them
+func (c *Clock) Millis(now time.Time) (int, int) {
+	return int(c.Remaining(chess.White, now).Milliseconds()), int(c.Remaining(chess.Black, now).Milliseconds())
+}
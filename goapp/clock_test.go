@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+func TestClockFischer(t *testing.T) {
+	start := time.Now()
+	clock := NewClock(ClockSettings{Initial: time.Minute, Increment: 5 * time.Second, Mode: FischerIncrement}, start)
+
+	if remaining := clock.Remaining(chess.White, start.Add(10*time.Second)); remaining != 50*time.Second {
+		t.Errorf("expected white's clock to run, got %s", remaining)
+	}
+	if remaining := clock.Remaining(chess.Black, start.Add(10*time.Second)); remaining != time.Minute {
+		t.Errorf("expected black's clock to be stopped, got %s", remaining)
+	}
+
+	// the full increment is added, even for a fast move
+	clock.Press(start.Add(2 * time.Second))
+	if remaining := clock.Remaining(chess.White, start.Add(time.Hour)); remaining != 63*time.Second {
+		t.Errorf("expected white to get the full increment, got %s", remaining)
+	}
+	if clock.Turn() != chess.Black {
+		t.Error("expected black to move")
+	}
+
+	wtime, btime := clock.Millis(start.Add(12 * time.Second))
+	if wtime != 63000 || btime != 50000 {
+		t.Errorf("expected 63000 and 50000, got %d and %d", wtime, btime)
+	}
+}
+
+func TestClockBronstein(t *testing.T) {
+	start := time.Now()
+	clock := NewClock(ClockSettings{Initial: time.Minute, Increment: 5 * time.Second, Mode: BronsteinIncrement}, start)
+
+	// a fast move gives back the time spent only
+	clock.Press(start.Add(2 * time.Second))
+	if remaining := clock.Remaining(chess.White, start); remaining != time.Minute {
+		t.Errorf("expected white's time to be given back, got %s", remaining)
+	}
+
+	// a slow move gives back the increment
+	clock.Press(start.Add(12 * time.Second))
+	if remaining := clock.Remaining(chess.Black, start); remaining != 55*time.Second {
+		t.Errorf("expected black to get the increment back, got %s", remaining)
+	}
+}
+
+func TestClockFlag(t *testing.T) {
+	start := time.Now()
+	clock := NewClock(ClockSettings{Initial: time.Minute}, start)
+
+	if _, flagged := clock.Flagged(start.Add(59 * time.Second)); flagged {
+		t.Error("expected white to have time left")
+	}
+	color, flagged := clock.Flagged(start.Add(61 * time.Second))
+	if !flagged || color != chess.White {
+		t.Errorf("expected white to flag, got %v %v", color, flagged)
+	}
+	if remaining := clock.Remaining(chess.White, start.Add(61*time.Second)); remaining != 0 {
+		t.Errorf("expected the remaining time not to be negative, got %s", remaining)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/aherve/eChess/goapp/lichess"
+)
+
+const ConfigFile = "config.json"
+
+type SeekPreset struct {
+	Label     string `json:"label"`
+	Time      int    `json:"time"`      // minutes
+	Increment int    `json:"increment"` // seconds
+	Rated     bool   `json:"rated"`
+	RatingMin int    `json:"ratingMin"`
+	RatingMax int    `json:"ratingMax"`
+	// When set, RatingMin and RatingMax are offsets from our own rating
+	RelativeRating bool `json:"relativeRating"`
+}
+
+func (p SeekPreset) SeekRequest() lichess.SeekRequest {
+	return lichess.SeekRequest{
+		Rated:     p.Rated,
+		Time:      p.Time,
+		Increment: p.Increment,
+		RatingRange: lichess.RatingRange{
+			Min:      p.RatingMin,
+			Max:      p.RatingMax,
+			Relative: p.RelativeRating,
+		},
+	}
+}
+
+// defaultSeekPresets are offered until presets are configured
+func defaultSeekPresets() []SeekPreset {
+	return []SeekPreset{
+		{Label: "15|10", Time: 15, Increment: 10, Rated: true},
+		{Label: "15|30", Time: 15, Increment: 30, Rated: true},
+		{Label: "30|20", Time: 30, Increment: 20, Rated: true},
+		{Label: "30|30", Time: 30, Increment: 30, Rated: true},
+	}
+}
+
+type Config struct {
+	AutoDecline AutoDeclineRules `json:"autoDecline"`
+	AutoAbort   AutoAbortRules   `json:"autoAbort"`
+	Presets     []SeekPreset     `json:"seekPresets"` // nil for the default presets
+	Engine      EngineConfig     `json:"engine"`
+
+	path string
+	mu   sync.RWMutex
+}
+
+// NewConfig returns the default configuration. Each call builds new values, so the configuration can be decoded into them
+func NewConfig() *Config {
+	return &Config{
+		AutoAbort: defaultAutoAbortRules(),
+		Engine:    defaultEngineConfig(),
+		path:      ConfigFile,
+	}
+}
+
+// LoadConfig reads the configuration file. A missing file is not an error, and produces the default configuration
+func LoadConfig(path string) (*Config, error) {
+	config := NewConfig()
+	config.path = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (c *Config) SeekPresets() []SeekPreset {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.Presets == nil {
+		return defaultSeekPresets()
+	}
+	return c.Presets
+}
+
+// SetSeekPresets replaces the seek presets and persists the configuration
+func (c *Config) SetSeekPresets(presets []SeekPreset) error {
+	c.mu.Lock()
+	c.Presets = presets
+	c.mu.Unlock()
+
+	return c.Save()
+}
+
+func (c *Config) Save() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0644)
+}
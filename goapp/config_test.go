@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aherve/eChess/goapp/lichess"
+)
+
+func TestLoadMissingConfig(t *testing.T) {
+	config, err := LoadConfig(filepath.Join(t.TempDir(), "config.json"))
+	if err != nil {
+		t.Fatalf("expected missing config to load, got %v", err)
+	}
+	if !reflect.DeepEqual(config.SeekPresets(), defaultSeekPresets()) {
+		t.Errorf("expected default presets, got %v", config.SeekPresets())
+	}
+}
+
+func TestLoadConfigKeepsDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"seekPresets":[{"label":"1|0","time":1,"increment":0}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	presets := config.SeekPresets()
+	if len(presets) != 1 || presets[0].Label != "1|0" || presets[0].Rated {
+		t.Errorf("expected a casual 1|0 preset, got %+v", presets)
+	}
+	if defaults := NewConfig().SeekPresets(); defaults[0].Label != "15|10" || !defaults[0].Rated {
+		t.Errorf("expected the default presets to be left untouched, got %+v", defaults)
+	}
+}
+
+func TestLoadAutoAbortRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"autoAbort":{"skipSpeeds":["bullet"]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if !reflect.DeepEqual(config.AutoAbort.SkipSpeeds, []lichess.GameSpeed{lichess.Bullet}) {
+		t.Errorf("expected bullet games not to be aborted, got %v", config.AutoAbort.SkipSpeeds)
+	}
+	if defaults := NewConfig().AutoAbort; !reflect.DeepEqual(defaults.SkipSpeeds, []lichess.GameSpeed{lichess.Classical}) {
+		t.Errorf("expected the default rules to be left untouched, got %v", defaults.SkipSpeeds)
+	}
+}
+
+func TestSaveSeekPresets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	presets := []SeekPreset{{Label: "blitz", Time: 5, Increment: 3, RatingMin: -200, RatingMax: 200, RelativeRating: true}}
+	if err := config.SetSeekPresets(presets); err != nil {
+		t.Fatalf("failed to save presets: %v", err)
+	}
+
+	reloaded, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("failed to reload config: %v", err)
+	}
+	if !reflect.DeepEqual(reloaded.SeekPresets(), presets) {
+		t.Errorf("expected presets %v after reload, got %v", presets, reloaded.SeekPresets())
+	}
+
+	req := reloaded.SeekPresets()[0].SeekRequest()
+	if req.Time != 5 || req.Increment != 3 || req.Rated || !req.RatingRange.Relative || req.RatingRange.Min != -200 {
+		t.Errorf("unexpected seek request %+v", req)
+	}
+}
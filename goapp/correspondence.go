@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aherve/eChess/goapp/lichess"
+)
+
+// nextCorrespondenceGame returns the first correspondence game waiting for our move. Lichess lists the most urgent games first
+func nextCorrespondenceGame(games []lichess.GameEvent) (lichess.GameEvent, bool) {
+	for _, game := range games {
+		if game.Speed == lichess.Correspondence && game.IsMyTurn {
+			return game, true
+		}
+	}
+	return lichess.GameEvent{}, false
+}
+
+// CorrespondenceLag is how long lichess may still list a correspondence game as our turn after we moved
+const CorrespondenceLag = time.Minute
+
+// CorrespondenceTracker remembers which correspondence games wait for our move, to notice when opponents reply
+type CorrespondenceTracker struct {
+	myTurn map[string]bool
+	primed bool
+	// games we left because it was not our turn anymore, and when
+	left map[string]time.Time
+
+	mu sync.Mutex
+}
+
+func NewCorrespondenceTracker() *CorrespondenceTracker {
+	return &CorrespondenceTracker{
+		myTurn: map[string]bool{},
+		left:   map[string]time.Time{},
+	}
+}
+
+// Left records that we left a game because it is not our turn, so it is not picked again while lichess lists it as our turn
+func (t *CorrespondenceTracker) Left(gameId string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.left[gameId] = now
+}
+
+// Next returns the next correspondence game waiting for our move, skipping the games we just left
+func (t *CorrespondenceTracker) Next(games []lichess.GameEvent, now time.Time) (lichess.GameEvent, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	waiting := []lichess.GameEvent{}
+	for _, game := range games {
+		if leftAt, ok := t.left[game.GameId]; ok && now.Sub(leftAt) < CorrespondenceLag {
+			continue
+		}
+		waiting = append(waiting, game)
+	}
+	return nextCorrespondenceGame(waiting)
+}
+
+// Update records the ongoing games, and returns the correspondence games that became our turn since the previous update
+func (t *CorrespondenceTracker) Update(games []lichess.GameEvent) []lichess.GameEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	replied := []lichess.GameEvent{}
+	myTurn := map[string]bool{}
+	for _, game := range games {
+		if game.Speed != lichess.Correspondence || !game.IsMyTurn {
+			// lichess caught up with our move
+			delete(t.left, game.GameId)
+			continue
+		}
+		myTurn[game.GameId] = true
+		if t.primed && !t.myTurn[game.GameId] {
+			replied = append(replied, game)
+		}
+	}
+
+	t.myTurn = myTurn
+	t.primed = true
+	return replied
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aherve/eChess/goapp/lichess"
+)
+
+func TestNextCorrespondenceGame(t *testing.T) {
+	games := []lichess.GameEvent{
+		{GameId: "blitz", Speed: lichess.Blitz, IsMyTurn: true},
+		{GameId: "waiting", Speed: lichess.Correspondence, IsMyTurn: false},
+		{GameId: "mine", Speed: lichess.Correspondence, IsMyTurn: true},
+	}
+	if game, ok := nextCorrespondenceGame(games); !ok || game.GameId != "mine" {
+		t.Errorf("expected the correspondence game waiting for our move but got %v, %v", game.GameId, ok)
+	}
+
+	if _, ok := nextCorrespondenceGame(games[:2]); ok {
+		t.Errorf("expected no correspondence game to play")
+	}
+}
+
+func TestCorrespondenceTracker(t *testing.T) {
+	tracker := NewCorrespondenceTracker()
+
+	games := []lichess.GameEvent{
+		{GameId: "a", Speed: lichess.Correspondence, IsMyTurn: true},
+		{GameId: "b", Speed: lichess.Correspondence, IsMyTurn: false},
+	}
+	if replied := tracker.Update(games); len(replied) != 0 {
+		t.Errorf("expected the first update not to notify but got %v", replied)
+	}
+
+	games[1].IsMyTurn = true
+	replied := tracker.Update(games)
+	if len(replied) != 1 || replied[0].GameId != "b" {
+		t.Errorf("expected b to be notified but got %v", replied)
+	}
+
+	if replied := tracker.Update(games); len(replied) != 0 {
+		t.Errorf("expected no notification when nothing changed but got %v", replied)
+	}
+
+	// we played in a, then the opponent replied
+	games[0].IsMyTurn = false
+	tracker.Update(games)
+	games[0].IsMyTurn = true
+	replied = tracker.Update(games)
+	if len(replied) != 1 || replied[0].GameId != "a" {
+		t.Errorf("expected a to be notified but got %v", replied)
+	}
+}
+
+func TestCorrespondenceTrackerSkipsLeftGames(t *testing.T) {
+	tracker := NewCorrespondenceTracker()
+	now := time.Now()
+
+	// lichess still lists a as our turn after we moved
+	games := []lichess.GameEvent{
+		{GameId: "a", Speed: lichess.Correspondence, IsMyTurn: true},
+		{GameId: "b", Speed: lichess.Correspondence, IsMyTurn: true},
+	}
+	tracker.Left("a", now)
+	if game, ok := tracker.Next(games, now); !ok || game.GameId != "b" {
+		t.Errorf("expected the game we left to be skipped but got %v, %v", game.GameId, ok)
+	}
+	if _, ok := tracker.Next(games[:1], now.Add(time.Second)); ok {
+		t.Errorf("expected no game while lichess lags behind our move")
+	}
+	if game, ok := tracker.Next(games[:1], now.Add(CorrespondenceLag)); !ok || game.GameId != "a" {
+		t.Errorf("expected the game to be picked once the lag is over but got %v, %v", game.GameId, ok)
+	}
+
+	// lichess caught up, then the opponent replied
+	tracker.Left("a", now)
+	games[0].IsMyTurn = false
+	tracker.Update(games)
+	games[0].IsMyTurn = true
+	if game, ok := tracker.Next(games[:1], now); !ok || game.GameId != "a" {
+		t.Errorf("expected the game to be picked after the opponent replied but got %v, %v", game.GameId, ok)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"strconv"
+	"time"
+
+	"github.com/aherve/eChess/goapp/lichess"
+	"github.com/aherve/eChess/goapp/uci"
+	"github.com/notnil/chess"
+)
+
+// Offline games are played against a local UCI engine
+type EngineConfig struct {
+	Path string   `json:"path"`
+	Args []string `json:"args"`
+}
+
+func defaultEngineConfig() EngineConfig {
+	return EngineConfig{Path: "stockfish"}
+}
+
+type EngineGameSettings struct {
+	Level     int    // skill level, from 0 to 20
+	Color     string // "white", "black" or "random"
+	Time      int    // minutes
+	Increment int    // seconds
+}
+
+// Rematch returns the settings of a game with colors swapped
+func (s EngineGameSettings) Rematch(color string) LocalGameSettings {
+	s.Color = "white"
+	if color == "white" {
+		s.Color = "black"
+	}
+	return s
+}
+
+// EngineGame plays a game against a local engine. It is the game backend during offline games
+type EngineGame struct {
+	*localGame
+	color    string // our color
+	settings EngineGameSettings
+	engine   *uci.Engine
+}
+
+func NewEngineGame(engine *uci.Engine, settings EngineGameSettings) *EngineGame {
+	color := settings.Color
+	if color != "white" && color != "black" {
+		color = []string{"white", "black"}[rand.IntN(2)]
+	}
+
+	id := fmt.Sprintf("offline%d", time.Now().Unix())
+	return &EngineGame{
+		localGame: newLocalGame(lichess.GameEvent{
+			FullID:   id,
+			GameId:   id,
+			Color:    color,
+			Opponent: lichess.Opponent{Username: engine.Name, AI: settings.Level},
+			Speed:    lichess.SeekRequest{Time: settings.Time, Increment: settings.Increment}.Speed(),
+		}, ClockSettings{
+			Initial:   time.Duration(settings.Time) * time.Minute,
+			Increment: time.Duration(settings.Increment) * time.Second,
+			Mode:      FischerIncrement,
+		}),
+		color:    color,
+		settings: settings,
+		engine:   engine,
+	}
+}
+
+func (g *EngineGame) Color() string {
+	return g.color
+}
+
+func (g *EngineGame) Settings() EngineGameSettings {
+	return g.settings
+}
+
+func (g *EngineGame) PlaysBothSides() bool {
+	return false
+}
+
+// StreamGame plays the game until it ends, or ctx is cancelled. The engine is closed when the game ends
+func (g *EngineGame) StreamGame(ctx context.Context, gameID string, chans *lichess.LichessEventChans) {
+	defer g.engine.Close()
+	g.run(ctx, chans, g.playTurn)
+}
+
+func (g *EngineGame) playTurn(ctx context.Context) (string, string) {
+	turnCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	engineMoves := make(chan string, 1)
+	if g.turn() != g.color {
+		go g.think(turnCtx, engineMoves)
+	}
+	flag := g.flag()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return "", ""
+		case move := <-g.boardMoves:
+			if g.turn() != g.color {
+				log.Printf("Ignoring move %s, it's not our turn", move)
+				continue
+			}
+			if _, err := g.apply(move); err != nil {
+				log.Printf("Invalid move %s: %v", move, err)
+				continue
+			}
+			return "", ""
+		case move := <-engineMoves:
+			if move == "" {
+				// the engine failed, which counts as a resignation
+				return "resign", g.color
+			}
+			if _, err := g.apply(move); err != nil {
+				log.Printf("The engine played an invalid move %s: %v", move, err)
+				return "resign", g.color
+			}
+			return "", ""
+		case <-flag:
+			return "outoftime", opposite(g.turn())
+		case command := <-g.commands:
+			switch command {
+			case "resign":
+				return "resign", opposite(g.color)
+			case "abort":
+				if len(g.moves) < 2 {
+					return "aborted", ""
+				}
+				log.Println("Too late to abort the game")
+			case "draw":
+				// the engine only agrees to draws that can be claimed
+				for _, method := range g.chessGame.EligibleDraws() {
+					if method == chess.ThreefoldRepetition || method == chess.FiftyMoveRule {
+						return "draw", ""
+					}
+				}
+				log.Println("The engine declines the draw")
+			}
+		}
+	}
+}
+
+func (g *EngineGame) think(ctx context.Context, moves chan string) {
+	wtime, btime := g.clock.Millis(time.Now())
+	increment := g.settings.Increment * 1000
+	move, err := g.engine.BestMove(ctx, uci.Position{Moves: g.moves}, uci.SearchLimits{
+		WTime: wtime,
+		BTime: btime,
+		WInc:  increment,
+		BInc:  increment,
+	})
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		log.Printf("Engine error: %v", err)
+	}
+	moves <- move
+}
+
+// Play starts the engine, and plays the game with the engine as backend until it ends
+func (settings EngineGameSettings) Play(state *MainState) {
+	ctx := state.Context()
+	config := state.Config().Engine
+	engine, err := uci.Start(ctx, config.Path, config.Args...)
+	if err != nil {
+		log.Printf("Error starting the engine: %v", err)
+		state.UIState().Input <- ChallengeFailed
+		return
+	}
+	if err := engine.SetOption(ctx, "Skill Level", strconv.Itoa(settings.Level)); err != nil {
+		log.Printf("Error setting the engine level: %v", err)
+	}
+	if err := engine.NewGame(ctx); err != nil {
+		log.Printf("Error starting a new engine game: %v", err)
+	}
+
+	game := NewEngineGame(engine, settings)
+	log.Printf("Starting offline game %s against %s level %d, you play %s", game.ID(), engine.Name, settings.Level, game.Color())
+
+	state.SetBackend(game)
+	defer state.SetBackend(LichessBackend{})
+
+	state.Game().UpdateFromFindGame(game.GameEvent())
+	handleGame(state)
+}
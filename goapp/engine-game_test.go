@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aherve/eChess/goapp/lichess"
+	"github.com/aherve/eChess/goapp/uci"
+)
+
+// fakeEngine always answers e7e5
+const fakeEngine = `#!/bin/sh
+while read line; do
+  case "$line" in
+    uci) echo "id name Fake Engine"; echo "uciok";;
+    isready) echo "readyok";;
+    go*) echo "bestmove e7e5";;
+    quit) exit 0;;
+  esac
+done
+`
+
+func startEngineGame(t *testing.T, settings EngineGameSettings) (*EngineGame, *lichess.LichessEventChans, context.CancelFunc) {
+	path := filepath.Join(t.TempDir(), "engine.sh")
+	if err := os.WriteFile(path, []byte(fakeEngine), 0755); err != nil {
+		t.Fatalf("failed to write fake engine: %v", err)
+	}
+	engine, err := uci.Start(context.Background(), path)
+	if err != nil {
+		t.Fatalf("failed to start fake engine: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	game := NewEngineGame(engine, settings)
+	chans := lichess.NewLichessEventChans()
+	go game.StreamGame(ctx, game.ID(), chans)
+	return game, chans, cancel
+}
+
+func TestEngineGame(t *testing.T) {
+	game, chans, cancel := startEngineGame(t, EngineGameSettings{Level: 3, Color: "white", Time: 5, Increment: 3})
+	defer cancel()
+	ctx := context.Background()
+
+	full := <-chans.GameFullChan
+	if full.InitialFen != "startpos" || full.Clock.Initial != 300000 || full.Clock.Increment != 3000 {
+		t.Errorf("unexpected game description %+v", full)
+	}
+	if event := game.GameEvent(); event.Opponent.Username != "Fake Engine" || event.Opponent.AI != 3 || event.Color != "white" {
+		t.Errorf("unexpected game event %+v", event)
+	}
+
+	if err := game.PlayMove(ctx, game.ID(), "e2e4"); err != nil {
+		t.Fatalf("failed to play: %v", err)
+	}
+	if state := <-chans.GameStateChan; state.Moves != "e2e4" || state.Status != "started" {
+		t.Errorf("expected our move, got %+v", state)
+	}
+	if state := <-chans.GameStateChan; state.Moves != "e2e4 e7e5" {
+		t.Errorf("expected the engine to answer, got %+v", state)
+	}
+
+	// the game is too advanced to be aborted, and draws can't be claimed
+	game.Abort(ctx, game.ID())
+	game.Draw(ctx, game.ID(), true)
+	game.Resign(ctx, game.ID())
+	state := <-chans.GameStateChan
+	if state.Status != "resign" || state.Winner != "black" {
+		t.Errorf("expected us to resign, got %+v", state)
+	}
+	<-chans.GameEnded
+
+	if err := game.PlayMove(ctx, game.ID(), "g1f3"); err == nil {
+		t.Error("expected moves to be rejected once the game is over")
+	}
+	if games, _ := game.ListGames(ctx); len(games) != 0 {
+		t.Errorf("expected the game to be over, got %+v", games)
+	}
+}
+
+func TestEngineGameAbort(t *testing.T) {
+	game, chans, cancel := startEngineGame(t, EngineGameSettings{Level: 0, Color: "white", Time: 1})
+	defer cancel()
+
+	<-chans.GameFullChan
+	game.Abort(context.Background(), game.ID())
+	if state := <-chans.GameStateChan; state.Status != "aborted" || state.Winner != "" {
+		t.Errorf("expected the game to be aborted, got %+v", state)
+	}
+	<-chans.GameEnded
+}
+
+func TestEngineGameRematch(t *testing.T) {
+	settings := EngineGameSettings{Level: 4, Color: "random", Time: 10, Increment: 5}
+	rematch := settings.Rematch("black").(EngineGameSettings)
+	if rematch.Color != "white" || rematch.Level != 4 || rematch.Time != 10 || rematch.Increment != 5 {
+		t.Errorf("unexpected rematch settings %+v", rematch)
+	}
+	if settings.Rematch("white").(EngineGameSettings).Color != "black" {
+		t.Error("expected colors to be swapped")
+	}
+}
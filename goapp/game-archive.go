@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aherve/eChess/goapp/archive"
+	"github.com/aherve/eChess/goapp/lichess"
+	"github.com/notnil/chess"
+)
+
+// Finished games are stored in this directory, as PGN files
+const ArchiveDir = "games"
+
+// archiveGame stores the PGN of a finished game. Lichess' export is preferred, and the PGN is built from our moves when it is not available
+func archiveGame(state *MainState, result *GameResult) {
+	gameArchive := state.Archive()
+	if gameArchive == nil || result.Outcome == GameAborted {
+		return
+	}
+
+	// games are dated when they started, or when they are archived if lichess didn't tell
+	date := result.StartedAt
+	if date.IsZero() {
+		date = time.Now()
+	}
+	pgn := buildPGN(result, date)
+	if !result.Offline {
+		// the export is richer than our moves, with clocks and lichess' tags
+		exported, err := lichess.ExportGamePGN(state.Context(), result.GameID)
+		if err != nil {
+			log.Printf("Error exporting game %s, building the PGN from our moves: %v", result.GameID, err)
+		} else {
+			pgn = exported
+		}
+	}
+
+	entry := archive.Entry{
+		GameID:   result.GameID,
+		Date:     date,
+		Opponent: result.Opponent.Username,
+		Color:    result.Color,
+		Speed:    string(result.Speed),
+		Outcome:  outcomeName(result.Outcome),
+		Result:   pgnResult(result),
+		Reason:   result.Reason,
+		Rated:    result.Rated,
+	}
+	if err := gameArchive.Add(entry, pgn); err != nil {
+		log.Printf("Error archiving game %s: %v", result.GameID, err)
+		return
+	}
+	log.Printf("Game %s archived in %s", result.GameID, gameArchive.Dir())
+}
+
+func outcomeName(outcome UIInput) string {
+	switch outcome {
+	case GameWon:
+		return "won"
+	case GameLost:
+		return "lost"
+	case GameDrawn:
+		return "drawn"
+	default:
+		return "aborted"
+	}
+}
+
+// pgnResult returns the result of the game in PGN notation
+func pgnResult(result *GameResult) string {
+	whiteWon := (result.Outcome == GameWon) == (result.Color == "white")
+	switch {
+	case result.Outcome == GameDrawn:
+		return "1/2-1/2"
+	case result.Outcome == GameAborted:
+		return "*"
+	case whiteWon:
+		return "1-0"
+	default:
+		return "0-1"
+	}
+}
+
+// buildPGN writes the PGN of a game from its moves, when lichess' export is not available. Our own name is unknown offline
+func buildPGN(result *GameResult, date time.Time) string {
+	white, black := "?", result.Opponent.Username
+	if result.Color == "black" {
+		white, black = black, white
+	}
+
+	mode := "Casual"
+	if result.Rated {
+		mode = "Rated"
+	}
+	gameID := result.GameID
+	if len(gameID) > 8 {
+		gameID = gameID[:8]
+	}
+
+	site := "https://lichess.org/" + gameID
+	if result.Offline {
+		mode, site = "Offline", "eChess"
+	}
+
+	tags := [][2]string{
+		{"Event", fmt.Sprintf("%s %s game", mode, result.Speed)},
+		{"Site", site},
+		{"Date", date.Format("2006.01.02")},
+		{"White", white},
+		{"Black", black},
+		{"Result", pgnResult(result)},
+	}
+	if result.Clock != nil {
+		tags = append(tags, [2]string{"TimeControl", fmt.Sprintf("%d+%d", result.Clock.Initial/1000, result.Clock.Increment/1000)})
+	}
+	if result.Variant != "" && result.Variant != "standard" {
+		tags = append(tags, [2]string{"Variant", result.Variant})
+	}
+	if result.InitialFen != "" && result.InitialFen != "startpos" {
+		tags = append(tags, [2]string{"SetUp", "1"}, [2]string{"FEN", result.InitialFen})
+	}
+	tags = append(tags, [2]string{"Termination", result.Reason})
+
+	var sb strings.Builder
+	for _, tag := range tags {
+		fmt.Fprintf(&sb, "[%s %q]\n", tag[0], tag[1])
+	}
+	sb.WriteString("\n")
+	sb.WriteString(moveText(result))
+	sb.WriteString(" " + pgnResult(result) + "\n")
+	return sb.String()
+}
+
+// moveText writes the moves in standard algebraic notation. Games that can't be replayed from the standard position keep their UCI moves in a comment
+func moveText(result *GameResult) string {
+	g, err := lichess.NewChessGameFromMoves(result.Moves)
+	if err != nil || (result.InitialFen != "" && result.InitialFen != "startpos") {
+		return fmt.Sprintf("{ UCI moves: %s }", strings.Join(result.Moves, " "))
+	}
+
+	positions := g.Positions()
+	words := []string{}
+	for i, move := range g.Moves() {
+		if i%2 == 0 {
+			words = append(words, fmt.Sprintf("%d.", i/2+1))
+		}
+		words = append(words, chess.AlgebraicNotation{}.Encode(positions[i], move))
+	}
+	return strings.Join(words, " ")
+}
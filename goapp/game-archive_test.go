@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aherve/eChess/goapp/lichess"
+)
+
+func TestPgnResult(t *testing.T) {
+	cases := []struct {
+		outcome  UIInput
+		color    string
+		expected string
+	}{
+		{GameWon, "white", "1-0"},
+		{GameWon, "black", "0-1"},
+		{GameLost, "white", "0-1"},
+		{GameLost, "black", "1-0"},
+		{GameDrawn, "black", "1/2-1/2"},
+	}
+	for _, c := range cases {
+		if result := pgnResult(&GameResult{Outcome: c.outcome, Color: c.color}); result != c.expected {
+			t.Errorf("expected %s playing %s to be %s, got %s", c.outcome, c.color, c.expected, result)
+		}
+	}
+}
+
+func TestBuildPGN(t *testing.T) {
+	result := &GameResult{
+		Outcome:  GameWon,
+		Reason:   "Checkmate",
+		Color:    "black",
+		Opponent: lichess.Opponent{Username: "opponent"},
+		Speed:    lichess.Rapid,
+		Clock:    &lichess.GameClock{Initial: 900000, Increment: 10000},
+		GameID:   "abcdefghijkl",
+		Rated:    true,
+		Moves:    []string{"f2f3", "e7e5", "g2g4", "d8h4"},
+	}
+
+	pgn := buildPGN(result, time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC))
+	for _, expected := range []string{
+		`[Event "Rated rapid game"]`,
+		`[Site "https://lichess.org/abcdefgh"]`,
+		`[Date "2025.06.01"]`,
+		`[White "opponent"]`,
+		`[Black "?"]`,
+		`[TimeControl "900+10"]`,
+		"1. f3 e5 2. g4 Qh4# 0-1",
+	} {
+		if !strings.Contains(pgn, expected) {
+			t.Errorf("expected PGN to contain %q, got\n%s", expected, pgn)
+		}
+	}
+
+	result.InitialFen = "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1"
+	result.Moves = []string{"e2e4"}
+	if pgn := buildPGN(result, time.Now()); !strings.Contains(pgn, "{ UCI moves: e2e4 }") || !strings.Contains(pgn, `[SetUp "1"]`) {
+		t.Errorf("expected games from a position to keep their UCI moves, got\n%s", pgn)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aherve/eChess/goapp/lichess"
+)
+
+// ErrMoveRejected is returned by backends refusing a move, which must not be sent again
+var ErrMoveRejected = errors.New("move rejected")
+
+// GameBackend plays the games driven by the board. Lichess is the default backend, and offline games plug in their own
+type GameBackend interface {
+	// ListGames returns the ongoing games
+	ListGames(ctx context.Context) ([]lichess.GameEvent, error)
+	// FindGame looks up an ongoing game by its 8 characters id, even when ListGames doesn't return it yet
+	FindGame(ctx context.Context, gameID string) (lichess.GameEvent, error)
+	// StreamGame sends the events of a game until it ends, fails for good (on StreamFailed) or ctx is cancelled
+	StreamGame(ctx context.Context, gameID string, chans *lichess.LichessEventChans)
+	// PlayMove returns ErrMoveRejected when the move is refused. Other errors mean the move may not have reached the backend
+	PlayMove(ctx context.Context, gameID, move string) error
+	Resign(ctx context.Context, gameID string)
+	Abort(ctx context.Context, gameID string)
+	// Draw offers, accepts or claims a draw, or declines the opponent's offer
+	Draw(ctx context.Context, gameID string, accept bool)
+	Takeback(ctx context.Context, gameID string, accept bool)
+	ClaimVictory(ctx context.Context, gameID string)
+	// Seek looks for an opponent until the returned function is called. It returns nil when seeking is not possible
+	Seek(ctx context.Context, req lichess.SeekRequest) *context.CancelFunc
+	// Offline games are unknown to lichess: there is no opponent profile, rating change or export
+	Offline() bool
+	// PlaysBothSides tells whether the moves of both colors are made on the board
+	PlaysBothSides() bool
+}
+
+// LichessBackend plays games on lichess
+type LichessBackend struct{}
+
+func (LichessBackend) ListGames(ctx context.Context) ([]lichess.GameEvent, error) {
+	return lichess.ListPlayingGames(ctx)
+}
+
+func (LichessBackend) FindGame(ctx context.Context, gameID string) (lichess.GameEvent, error) {
+	return lichess.FindGame(ctx, gameID)
+}
+
+func (LichessBackend) StreamGame(ctx context.Context, gameID string, chans *lichess.LichessEventChans) {
+	lichess.StreamGame(ctx, gameID, chans)
+}
+
+func (LichessBackend) PlayMove(ctx context.Context, gameID, move string) error {
+	err := lichess.PlayMove(ctx, gameID, move)
+	if errors.Is(err, lichess.ErrBadMove) {
+		return fmt.Errorf("%w: %w", ErrMoveRejected, err)
+	}
+	return err
+}
+
+func (LichessBackend) Resign(ctx context.Context, gameID string) {
+	lichess.ResignGame(ctx, gameID)
+}
+
+func (LichessBackend) Abort(ctx context.Context, gameID string) {
+	lichess.AbortGame(ctx, gameID)
+}
+
+func (LichessBackend) Draw(ctx context.Context, gameID string, accept bool) {
+	lichess.Draw(ctx, gameID, accept)
+}
+
+func (LichessBackend) Takeback(ctx context.Context, gameID string, accept bool) {
+	lichess.Takeback(ctx, gameID, accept)
+}
+
+func (LichessBackend) ClaimVictory(ctx context.Context, gameID string) {
+	lichess.ClaimVictory(ctx, gameID)
+}
+
+func (LichessBackend) Seek(ctx context.Context, req lichess.SeekRequest) *context.CancelFunc {
+	return lichess.CreateSeek(ctx, req)
+}
+
+func (LichessBackend) Offline() bool {
+	return false
+}
+
+func (LichessBackend) PlaysBothSides() bool {
+	return false
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aherve/eChess/goapp/lichess"
+)
+
+type GameResult struct {
+	Outcome    UIInput // GameWon, GameLost, GameDrawn or GameAborted
+	Status     string
+	Reason     string
+	Color      string
+	Opponent   lichess.Opponent
+	Speed      lichess.GameSpeed
+	Clock      *lichess.GameClock
+	GameID     string
+	Rated      bool
+	Moves      []string
+	Variant    string
+	InitialFen string
+	MoveCount  int // full moves
+	Position   string
+	RatingDiff *int      // nil for casual games, or when lichess could not tell
+	Offline    bool      // played against the local engine
+	StartedAt  time.Time // zero when lichess didn't tell
+}
+
+func NewGameResult(game *lichess.Game) *GameResult {
+	moves := game.Moves()
+	result := &GameResult{
+		Status:     game.Status(),
+		Color:      game.Color(),
+		Opponent:   *game.Opponent(),
+		Speed:      game.Speed(),
+		Clock:      game.Clock(),
+		GameID:     game.FullID(),
+		Moves:      moves,
+		Variant:    game.Variant(),
+		InitialFen: game.InitialFen(),
+		MoveCount:  (len(moves) + 1) / 2,
+		Position:   game.ChessGame().Position().Board().Draw(),
+		StartedAt:  game.CreatedAt(),
+	}
+
+	switch {
+	case result.Status == "aborted" || result.Status == "noStart":
+		result.Outcome = GameAborted
+	case game.Winner() == game.Color():
+		result.Outcome = GameWon
+	case game.Winner() != "":
+		result.Outcome = GameLost
+	default:
+		result.Outcome = GameDrawn
+	}
+	result.Reason = describeStatus(result.Status, game.Winner())
+
+	return result
+}
+
+// describeStatus explains how the game ended, from lichess' terminal status
+func describeStatus(status, winner string) string {
+	loser := "white"
+	if winner == "white" {
+		loser = "black"
+	}
+
+	switch status {
+	case "mate":
+		return "Checkmate"
+	case "resign":
+		return fmt.Sprintf("%s resigned", capitalize(loser))
+	case "outoftime":
+		if winner == "" {
+			return "Time out with insufficient material"
+		}
+		return fmt.Sprintf("%s ran out of time", capitalize(loser))
+	case "timeout":
+		if winner == "" {
+			return "The opponent left the game, draw"
+		}
+		return fmt.Sprintf("%s left the game", capitalize(loser))
+	case "stalemate":
+		return "Stalemate"
+	case "draw":
+		return "Draw"
+	case "aborted":
+		return "Game aborted"
+	case "noStart":
+		return "The game did not start"
+	case "cheat":
+		return "Cheat detected"
+	case "variantEnd":
+		return "Variant ending"
+	case "":
+		return "Game ended"
+	default:
+		return status
+	}
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return string(s[0]-'a'+'A') + s[1:]
+}
+
+func (r *GameResult) Title() string {
+	switch r.Outcome {
+	case GameWon:
+		return "Victory !"
+	case GameLost:
+		return "Looooooose"
+	case GameAborted:
+		return "Game aborted"
+	default:
+		return "It's a draw ¯\\_(ツ)_/¯"
+	}
+}
+
+func (r *GameResult) String() string {
+	text := fmt.Sprintf("%s\n\n%s after %d moves against %s (%d)", r.Title(), r.Reason, r.MoveCount, r.Opponent.Username, r.Opponent.Rating)
+	if r.RatingDiff != nil {
+		text += fmt.Sprintf("\nRating change: %+d", *r.RatingDiff)
+	}
+	return text + "\n\n" + r.Position
+}
+
+// RematchRequest builds a challenge with the same settings, and colors swapped
+func (r *GameResult) RematchRequest() lichess.ChallengeRequest {
+	req := lichess.ChallengeRequest{
+		Rated: r.Rated,
+		Color: "white",
+	}
+	// lichess expects the 8 characters game id, without our secret
+	if gameId, err := lichess.ParseGameID(r.GameID); err == nil {
+		req.RematchOf = gameId
+	}
+	if r.Color == "white" {
+		req.Color = "black"
+	}
+	if r.Clock != nil {
+		req.ClockLimit = r.Clock.Initial / 1000
+		req.ClockIncrement = r.Clock.Increment / 1000
+	}
+	return req
+}
+
+// SeekRequest builds a seek with the time control of the game. Seeks count in whole minutes, so sub-minute clocks are rounded up
+func (r *GameResult) SeekRequest() lichess.SeekRequest {
+	req := lichess.SeekRequest{Rated: r.Rated}
+	if r.Clock != nil {
+		req.Time = (r.Clock.Initial + 59999) / 60000
+		req.Increment = r.Clock.Increment / 1000
+	}
+	return req
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aherve/eChess/goapp/lichess"
+)
+
+func TestDescribeStatus(t *testing.T) {
+	cases := []struct {
+		status   string
+		winner   string
+		expected string
+	}{
+		{"mate", "white", "Checkmate"},
+		{"resign", "white", "Black resigned"},
+		{"resign", "black", "White resigned"},
+		{"outoftime", "black", "White ran out of time"},
+		{"outoftime", "", "Time out with insufficient material"},
+		{"timeout", "white", "Black left the game"},
+		{"timeout", "", "The opponent left the game, draw"},
+		{"stalemate", "", "Stalemate"},
+		{"aborted", "", "Game aborted"},
+		{"", "", "Game ended"},
+	}
+	for _, c := range cases {
+		if actual := describeStatus(c.status, c.winner); actual != c.expected {
+			t.Errorf("expected %s/%s to be described as %q, got %q", c.status, c.winner, c.expected, actual)
+		}
+	}
+}
+
+func TestNewGameResult(t *testing.T) {
+	game := lichess.NewGame()
+	game.UpdateFromFindGame(lichess.GameEvent{FullID: "abcdefghijkl", Color: "black", Opponent: lichess.Opponent{Username: "friend", Rating: 1500}})
+	game.Update(lichess.GameStateEvent{Status: "mate", Winner: "black", Moves: "f2f3 e7e5 g2g4 d8h4"})
+
+	result := NewGameResult(game)
+	if result.Outcome != GameWon {
+		t.Errorf("expected the game to be won, got %s", result.Outcome)
+	}
+	if result.MoveCount != 2 {
+		t.Errorf("expected 2 moves, got %d", result.MoveCount)
+	}
+	if result.Reason != "Checkmate" {
+		t.Errorf("expected checkmate, got %s", result.Reason)
+	}
+
+	if !result.StartedAt.IsZero() {
+		t.Errorf("expected no start time before lichess tells, got %v", result.StartedAt)
+	}
+	game.UpdateFromGameFull(lichess.GameFullEvent{CreatedAt: 1748779200000, State: lichess.GameStateEvent{Status: "mate", Winner: "black", Moves: "f2f3 e7e5 g2g4 d8h4"}})
+	if started := NewGameResult(game).StartedAt; !started.Equal(time.UnixMilli(1748779200000)) {
+		t.Errorf("expected the game to start when lichess created it, got %v", started)
+	}
+
+	game.Update(lichess.GameStateEvent{Status: "aborted"})
+	if NewGameResult(game).Outcome != GameAborted {
+		t.Errorf("expected the game to be aborted")
+	}
+}
+
+func TestRematchAndSeekRequests(t *testing.T) {
+	result := &GameResult{
+		GameID: "abcdefghijkl",
+		Color:  "white",
+		Rated:  true,
+		Clock:  &lichess.GameClock{Initial: 900000, Increment: 10000},
+	}
+
+	rematch := result.RematchRequest()
+	if rematch.Color != "black" {
+		t.Errorf("expected colors to be swapped, got %s", rematch.Color)
+	}
+	if rematch.ClockLimit != 900 || rematch.ClockIncrement != 10 {
+		t.Errorf("expected a 900+10 clock, got %d+%d", rematch.ClockLimit, rematch.ClockIncrement)
+	}
+	if !rematch.Rated || rematch.RematchOf != "abcdefgh" {
+		t.Errorf("expected a rated rematch of abcdefgh, got %+v", rematch)
+	}
+
+	seek := result.SeekRequest()
+	if seek.Time != 15 || seek.Increment != 10 || !seek.Rated {
+		t.Errorf("expected a rated 15|10 seek, got %+v", seek)
+	}
+
+	// ½+0 bullet
+	result.Clock = &lichess.GameClock{Initial: 30000}
+	if seek := result.SeekRequest(); seek.Time != 1 {
+		t.Errorf("expected a sub-minute clock to seek 1 minute, got %d", seek.Time)
+	}
+}
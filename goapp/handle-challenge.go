@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/aherve/eChess/goapp/lichess"
+)
+
+// Challenges matching any of these rules are declined without asking
+type AutoDeclineRules struct {
+	Casual   bool                `json:"casual"`   // decline unrated challenges
+	Variants bool                `json:"variants"` // decline non-standard variants
+	Speeds   []lichess.GameSpeed `json:"speeds"`   // decline these speeds
+	// decline challengers rated below this. 0 means no minimum
+	MinRating int `json:"minRating"`
+}
+
+// DeclineReason returns the lichess reason for declining the challenge, or an empty string if the challenge should be shown to the player
+func (r AutoDeclineRules) DeclineReason(c lichess.Challenge) string {
+	if r.Casual && !c.Rated {
+		return "casual"
+	}
+	if r.Variants && c.Variant.Key != "standard" {
+		return "standard"
+	}
+	if slices.Contains(r.Speeds, c.Speed) {
+		return "timeControl"
+	}
+	if r.MinRating > 0 && c.Challenger.Rating < r.MinRating {
+		return "generic"
+	}
+	return ""
+}
+
+func handleIncomingEvents(state *MainState) {
+	ctx := state.Context()
+	account, err := lichess.GetAccount(ctx)
+	if err != nil {
+		log.Printf("Error fetching account, incoming challenges are disabled: %v", err)
+		return
+	}
+
+	chans := lichess.NewIncomingEventChans()
+	go func() {
+		for ctx.Err() == nil {
+			if err := lichess.StreamEvents(ctx, chans); err != nil {
+				log.Printf("Event stream error: %v", err)
+			}
+			log.Println("Event stream ended. Reconnecting in 3 seconds...")
+			time.Sleep(3 * time.Second)
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case challenge := <-chans.ChallengeChan:
+			if challenge.Challenger.ID == account.ID {
+				// our own outgoing challenge
+				continue
+			}
+			handleChallenge(state, challenge)
+		case challenge := <-chans.ChallengeCanceledChan:
+			if state.UIState().ClearChallenge(challenge.ID) {
+				log.Printf("Challenge %s was canceled by %s", challenge.ID, challenge.Challenger.Name)
+				state.UIState().Input <- ChallengeCanceled
+			}
+		}
+	}
+}
+
+func handleChallenge(state *MainState, challenge lichess.Challenge) {
+	log.Printf("Received challenge %s from %s", challenge.ID, challenge.Challenger.Name)
+
+	reason := state.Config().AutoDecline.DeclineReason(challenge)
+	if reason == "" && state.Game().FullID() != "" {
+		reason = "later"
+	}
+	if reason == "" && state.UIState().PendingChallenge() != nil {
+		reason = "later"
+	}
+
+	if reason != "" {
+		log.Printf("Auto-declining challenge %s (%s)", challenge.ID, reason)
+		if err := lichess.DeclineChallenge(state.Context(), challenge.ID, reason); err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	state.UIState().SetPendingChallenge(&challenge)
+	state.UIState().Input <- ChallengeReceived
+}
+
+func getChallengeText(c *lichess.Challenge) string {
+	mode := "casual"
+	if c.Rated {
+		mode = "rated"
+	}
+	timeControl := c.TimeControl.Show
+	if c.TimeControl.Type == "correspondence" {
+		timeControl = fmt.Sprintf("%d days", c.TimeControl.DaysPerTurn)
+	}
+	name := c.Challenger.Name
+	if c.Challenger.Title != "" {
+		name = c.Challenger.Title + " " + name
+	}
+	return fmt.Sprintf("%s (%d) challenges you\n\n%s %s %s · %s",
+		name,
+		c.Challenger.Rating,
+		timeControl,
+		mode,
+		c.Speed,
+		c.Variant.Name,
+	)
+}
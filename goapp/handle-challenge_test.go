@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aherve/eChess/goapp/lichess"
+)
+
+func TestDeclineReason(t *testing.T) {
+	challenge := lichess.Challenge{
+		ID:         "abc",
+		Challenger: lichess.ChallengePlayer{Name: "friend", Rating: 1500},
+		Variant:    lichess.Variant{Key: "standard", Name: "Standard"},
+		Rated:      true,
+		Speed:      lichess.Rapid,
+	}
+
+	// no rules: everything is accepted
+	if reason := (AutoDeclineRules{}).DeclineReason(challenge); reason != "" {
+		t.Errorf("expected no decline reason without rules, got %s", reason)
+	}
+
+	rules := AutoDeclineRules{
+		Casual:    true,
+		Variants:  true,
+		Speeds:    []lichess.GameSpeed{lichess.Blitz, lichess.Bullet},
+		MinRating: 1200,
+	}
+	if reason := rules.DeclineReason(challenge); reason != "" {
+		t.Errorf("expected rated standard rapid challenge to be accepted, got %s", reason)
+	}
+
+	casual := challenge
+	casual.Rated = false
+	if reason := rules.DeclineReason(casual); reason != "casual" {
+		t.Errorf("expected casual challenge to be declined with casual, got %s", reason)
+	}
+
+	variant := challenge
+	variant.Variant = lichess.Variant{Key: "chess960", Name: "Chess960"}
+	if reason := rules.DeclineReason(variant); reason != "standard" {
+		t.Errorf("expected variant challenge to be declined with standard, got %s", reason)
+	}
+
+	blitz := challenge
+	blitz.Speed = lichess.Blitz
+	if reason := rules.DeclineReason(blitz); reason != "timeControl" {
+		t.Errorf("expected blitz challenge to be declined with timeControl, got %s", reason)
+	}
+
+	lowRated := challenge
+	lowRated.Challenger.Rating = 1100
+	if reason := rules.DeclineReason(lowRated); reason != "generic" {
+		t.Errorf("expected low rated challenger to be declined with generic, got %s", reason)
+	}
+}
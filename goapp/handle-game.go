@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
-	"strings"
+	"slices"
 	"time"
 
 	"github.com/aherve/eChess/goapp/lichess"
@@ -15,27 +17,101 @@ const PlayDelay = 250 * time.Millisecond
 func runBackend(state *MainState) {
 
 	go handleBoard(state)
+	go handleIncomingEvents(state)
 
 	state.Board().sendLEDCommand(state.LitSquares())
-	for state.Game().FullID() == "" {
+	correspondence := state.Correspondence()
+	for state.Game().FullID() == "" && state.Context().Err() == nil {
 
-		err := lichess.FindPlayingGame(state.Game())
+		// offline games don't need lichess
+		if settings := state.UIState().TakeLocalGame(); settings != nil {
+			settings.Play(state)
+			continue
+		}
+
+		games, err := state.Backend().ListGames(state.Context())
+		if errors.Is(err, lichess.ErrUnauthorized) {
+			log.Printf("Lichess rejected our token: %v", err)
+			state.UIState().Input <- Unauthorized
+			state.UIState().WaitForGameChoice(30 * time.Second)
+			continue
+		}
 		if err != nil {
-			log.Fatalf("Error finding game: %v", err)
+			log.Printf("Error finding game: %v. Will try again in 3 seconds...", err)
+			state.UIState().WaitForGameChoice(3 * time.Second)
+			continue
+		}
+
+		if replied := correspondence.Update(games); len(replied) > 0 {
+			log.Printf("Opponents replied in %d correspondence games", len(replied))
+			state.UIState().SetRepliedGames(replied)
+			state.UIState().Input <- OpponentReplied
 		}
 
-		if state.Game().FullID() != "" {
+		var found lichess.GameEvent
+		var ok bool
+		if state.UIState().CorrespondenceMode() {
+			found, ok = correspondence.Next(games, time.Now())
+		} else {
+			found, ok = pickGame(state.UIState(), withSelectedGame(state, games))
+		}
+		if ok {
+			state.Game().UpdateFromFindGame(found)
 			handleGame(state)
 			continue
 		}
 
-		if state.Game().FullID() == "" {
-			log.Println("No game found. Will try again in 3 seconds...")
+		if len(games) == 0 || state.UIState().CorrespondenceMode() {
+			log.Println("No game to play. Will try again in 3 seconds...")
 			state.UIState().Input <- NoCurrentGame
-			time.Sleep(3 * time.Second)
-			continue
 		}
+		state.UIState().WaitForGameChoice(3 * time.Second)
+	}
+}
+
+// withSelectedGame adds the game attached from the UI, which lichess may not list yet. It is unselected if lichess doesn't know it as one of our ongoing games
+func withSelectedGame(state *MainState, games []lichess.GameEvent) []lichess.GameEvent {
+	selected := state.UIState().SelectedGame()
+	if selected == "" || slices.ContainsFunc(games, func(game lichess.GameEvent) bool { return game.GameId == selected }) {
+		return games
+	}
+
+	game, err := state.Backend().FindGame(state.Context(), selected)
+	if err != nil {
+		log.Printf("Cannot attach game %s: %v", selected, err)
+		state.UIState().SelectGame("")
+		state.UIState().Input <- GameNotFound
+		return games
+	}
+	return append(games, game)
+}
+
+// pickGame selects the game driven by the board. When several games are ongoing, the player chooses one from the UI
+func pickGame(uiState *UIState, games []lichess.GameEvent) (lichess.GameEvent, bool) {
+	if selected := uiState.SelectedGame(); selected != "" {
+		for _, game := range games {
+			if game.GameId == selected {
+				return game, true
+			}
+		}
+		log.Printf("Selected game %s is not ongoing anymore", selected)
+		uiState.SelectGame("")
+	}
+
+	switch len(games) {
+	case 0:
+		uiState.SetPlayingGames(games)
+		return lichess.GameEvent{}, false
+	case 1:
+		uiState.SetPlayingGames(games)
+		return games[0], true
 	}
+
+	if uiState.SetPlayingGames(games) {
+		log.Printf("%d ongoing games, waiting for the player to choose", len(games))
+		uiState.Input <- ChooseGame
+	}
+	return lichess.GameEvent{}, false
 }
 
 func handleBoard(state *MainState) {
@@ -44,109 +120,188 @@ func handleBoard(state *MainState) {
 
 			state.UpdateLitSquares()
 			state.Board().sendLEDCommand(state.LitSquares())
-			if state.Game().IsMyTurn() {
+
+			if state.AwaitingSync() {
+				// The board still shows the position before the takeback, which looks like a valid move. Wait until it's restored
+				if len(state.LitSquares()) > 0 {
+					continue
+				}
+				log.Println("Board is back in sync with the game")
+				state.SetAwaitingSync(false)
+			}
+
+			if state.Game().IsMyTurn() || state.Backend().PlaysBothSides() {
 				move, needsPromotion := findValidMove(state)
 				if move != "" && needsPromotion {
 					move = addPromotion(move, state.UIState())
 				}
-				state.CandidateMove().PlayWithDelay(gameID, move)
+				state.CandidateMove().PlayWithDelay(state.Context(), state.Backend(), gameID, move)
 			}
+		} else if state.Replay() != nil {
+			state.RefreshLEDs()
 		}
 	}
 }
 
-// Too many cheaters among provisional players. We abort the game right away.
-func abortIfOpponentIsProvisional(state *MainState) {
-	speed := state.Game().Speed()
-	// Actually, aborting on Classical is a bit greedy. Let's deactivate that for now.
-	if speed == lichess.Classical {
-		log.Println("Classical game detected, opponents aren't easy to find, so we'll skip provisional check.")
-		return
-	}
-
-	opponentName := state.Game().Opponent().Username
-	player, err := lichess.GetPlayer(opponentName)
-	if err != nil {
-		log.Printf("Error fetching opponent info: %v", err)
-		return
-	}
-
-	if player.IsProvisional(speed) {
-		log.Printf("Opponent %s is provisional on %s. Aborting game.", opponentName, speed)
-		lichess.AbortGame(state.Game().FullID())
-	} else {
-		log.Printf("Opponent %s is not provisional on %s. Moving on", opponentName, speed)
-	}
-}
-
 func handleGame(state *MainState) {
 	game := state.Game()
 	board := state.Board()
 
 	log.Println("Game ID:", game.FullID(), "You are playing as", game.Color())
 
-	go abortIfOpponentIsProvisional(state)
+	// correspondence games are resumed from any position: the board has to be set up before moves are detected
+	correspondence := game.Speed() == lichess.Correspondence
+	go handleOpponent(state)
 
 	state.UIState().Input <- GameStarted
 	go state.UIState().ClearSeek()
-	go state.PlayStartSequence()
+	if !correspondence {
+		go state.PlayStartSequence()
+	}
+
+	// the stream is stopped when we leave the game, which doesn't always mean it ended
+	ctx, cancel := context.WithCancel(state.Context())
+	defer cancel()
 
 	chans := lichess.NewLichessEventChans()
 	if gameID := game.FullID(); gameID != "" {
 		log.Printf("Starting streaming game %s, you play as %s\n", gameID, game.Color())
-		go lichess.StreamGame(gameID, chans)
+		go state.Backend().StreamGame(ctx, gameID, chans)
 	}
 
 	for {
 		select {
+		case <-state.Context().Done():
+			return
 		case evt := <-chans.ChatChan:
 			log.Printf("[%s]: %s", evt.UserName, evt.Text)
-			if isDrawOfferFromOpponent(evt, state) {
-				state.Game().SetOpponentOffersDraw(true)
-			}
 		case evt := <-chans.OpponentGoneChan:
 			log.Printf("OpponentGone: %+v\n", evt)
 			if evt.ClaimWinInSeconds <= 0 {
-				lichess.ClaimVictory(game.FullID())
+				state.Backend().ClaimVictory(state.Context(), game.FullID())
 			}
 		case evt := <-chans.GameStateChan:
+			previousMoves := len(game.Moves())
+			proposedTakeback := game.OpponentProposesTakeback()
+			offeredDraw := game.OpponentOffersDraw()
+
 			game.Update(evt)
 			state.UpdateLitSquares()
 			board.sendLEDCommand(state.LitSquares())
 			log.Println("Game updated", game.Moves())
+
+			if len(game.Moves()) < previousMoves {
+				handleTakenBack(state)
+			}
+			if !proposedTakeback && game.OpponentProposesTakeback() {
+				state.UIState().Input <- TakebackProposed
+			}
+			if !offeredDraw && game.OpponentOffersDraw() {
+				state.UIState().Input <- DrawOffered
+			}
+			if correspondence && state.UIState().CorrespondenceMode() && len(game.Moves()) > previousMoves && !game.IsMyTurn() {
+				log.Printf("Move sent in correspondence game %s, switching to the next game", game.FullID())
+				state.Correspondence().Left(game.GameID(), time.Now())
+				leaveGame(state)
+				state.UIState().Input <- CorrespondenceMoveSent
+				return
+			}
+		case evt := <-chans.GameFullChan:
+			previousMoves := len(game.Moves())
+			// after a reconnection, an offer made meanwhile only shows up here
+			proposedTakeback := game.OpponentProposesTakeback()
+			offeredDraw := game.OpponentOffersDraw()
+
+			game.UpdateFromGameFull(evt)
+			state.UpdateLitSquares()
+			board.sendLEDCommand(state.LitSquares())
+			log.Println("Game synchronised", game.Moves())
+
+			if len(game.Moves()) < previousMoves {
+				handleTakenBack(state)
+			} else if correspondence || len(game.Moves()) == 0 {
+				// the board has to be set up in the initial position of the game, which is not always the standard one
+				state.SetAwaitingSync(len(state.LitSquares()) > 0)
+			}
+			if !proposedTakeback && game.OpponentProposesTakeback() {
+				state.UIState().Input <- TakebackProposed
+			}
+			if !offeredDraw && game.OpponentOffersDraw() {
+				state.UIState().Input <- DrawOffered
+			}
+			if correspondence && state.UIState().CorrespondenceMode() && !game.IsMyTurn() {
+				// the list of ongoing games can lag behind the move we just sent: the game is skipped until lichess catches up
+				log.Printf("Not our turn in correspondence game %s, switching to the next game", game.FullID())
+				state.Correspondence().Left(game.GameID(), time.Now())
+				leaveGame(state)
+				state.UIState().Input <- CorrespondenceMoveSent
+				return
+			}
+		case err := <-chans.StreamFailed:
+			log.Printf("Lost the stream of game %s: %v", game.FullID(), err)
+			leaveGame(state)
+			if errors.Is(err, lichess.ErrUnauthorized) {
+				state.UIState().Input <- Unauthorized
+			}
+			return
 		case <-chans.GameEnded:
-			log.Printf("Game ended")
 			go state.PlayEndSequence()
 
-			if game.Winner() == game.Color() {
-				state.UIState().Input <- GameWon
-			} else if game.Winner() != "" {
-				state.UIState().Input <- GameLost
+			result := NewGameResult(game)
+			log.Printf("Game ended: %s (%s)", result.Outcome, result.Reason)
+			// offline games are neither rated nor known to lichess
+			result.Offline = state.Backend().Offline()
+			if !result.Offline {
+				if export, err := lichess.ExportGame(state.Context(), game.FullID()); err != nil {
+					log.Printf("Error fetching rating change: %v", err)
+				} else {
+					result.RatingDiff = export.Player(game.Color()).RatingDiff
+					result.Rated = export.Rated
+					if export.CreatedAt > 0 {
+						result.StartedAt = time.UnixMilli(export.CreatedAt)
+					}
+				}
+			}
+			if decision := state.UIState().AbortDecision(); decision != nil && decision.Abort && decision.GameID == game.FullID() {
+				result.Reason = "Auto-aborted: " + decision.Reason
+			}
+
+			// the finished game is not attached anymore
+			state.UIState().SelectGame("")
+			state.UIState().SetLastResult(result)
+			state.UIState().Input <- result.Outcome
+			if recorder, ok := state.Backend().(GameRecorder); ok && result.Outcome != GameAborted {
+				// the players fill the headers before the game is saved
+				state.UIState().SetPendingRecord(recorder.Record())
+				state.UIState().Input <- GameRecorded
 			} else {
-				state.UIState().Input <- GameDrawn
+				go archiveGame(state, result)
 			}
 
-			state.Game().Reset()
-			state.ResetLitSquares()
-			state.CandidateMove().Reset()
+			leaveGame(state)
 			return
 		}
 	}
 }
 
-// example draw offer:  [lichess]: White offers draw
-func isDrawOfferFromOpponent(chatLine lichess.ChatLineEvent, s *MainState) bool {
-	if chatLine.UserName != "lichess" {
-		return false
-	}
-	myColor := strings.ToLower(s.Game().Color())
-	lowerText := strings.ToLower(chatLine.Text)
+// leaveGame stops driving the current game with the board
+func leaveGame(state *MainState) {
+	state.Game().Reset()
+	state.ResetLitSquares()
+	state.CandidateMove().Reset()
+	state.SetAwaitingSync(false)
+	state.SetIllegalMove("")
+	state.ResetLift()
+}
 
-	if strings.HasPrefix(lowerText, myColor) {
-		return false
-	}
+// After a takeback, the player has to restore the previous position, guided by the LEDs
+func handleTakenBack(state *MainState) {
+	log.Println("Moves were taken back, waiting for the board to be restored")
 
-	return strings.Contains(chatLine.Text, "offers draw")
+	// cancel any move scheduled from the position before the takeback, and allow replaying the same move
+	state.CandidateMove().Reset()
+	state.SetAwaitingSync(len(state.LitSquares()) > 0)
+	state.UIState().Input <- TakenBack
 }
 
 func addPromotion(move string, uiState *UIState) string {
@@ -170,6 +325,25 @@ func findValidMove(state *MainState) (string, bool) {
 	litSquares := state.LitSquares()
 	boardState := state.Board().State()
 
+	// the illegal move is flagged until the board changes
+	illegal := ""
+	defer func() {
+		state.SetIllegalMove(illegal)
+	}()
+
+	// Chess960 castling moves the king and the rook on any squares: look for a castling position matching the board
+	liftedBack := state.TrackLift(len(state.Game().Moves()), len(litSquares) == 0)
+	current := state.Game().ChessGame().Position()
+	for move, position := range state.Game().CastlingMoves() {
+		// such a castle looks just like the current position, unless pieces were lifted in between
+		if sameOccupancy(position, current) && !liftedBack {
+			continue
+		}
+		if state.Board().Matches(position) {
+			return move, false
+		}
+	}
+
 	// must have 2 changes exactly
 	if len(litSquares) != 2 {
 		return "", false
@@ -202,10 +376,21 @@ func findValidMove(state *MainState) (string, bool) {
 		return move, needsPromotion
 	} else {
 		log.Printf("invalid move %s", move)
+		illegal = move
 		return "", false
 	}
 }
 
+// sameOccupancy returns whether both positions have pieces of the same colors on the same squares, which the board can't tell apart
+func sameOccupancy(a, b *chess.Position) bool {
+	for square := chess.A1; square <= chess.H8; square++ {
+		if a.Board().Piece(square).Color() != b.Board().Piece(square).Color() {
+			return false
+		}
+	}
+	return true
+}
+
 func getIndexFromCoordinates(i, j int) int8 {
 	return int8(8*j + i)
 }
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"testing"
 
+	"github.com/aherve/eChess/goapp/lichess"
 	"github.com/notnil/chess"
 )
 
@@ -35,3 +37,76 @@ func TestGetCoordinatesFromIndex(t *testing.T) {
 	}
 
 }
+
+func TestPickGame(t *testing.T) {
+	uiState := NewUIState()
+
+	if _, ok := pickGame(uiState, nil); ok {
+		t.Errorf("expected no game to be picked without ongoing games")
+	}
+
+	single := []lichess.GameEvent{{GameId: "aaaaaaaa"}}
+	if game, ok := pickGame(uiState, single); !ok || game.GameId != "aaaaaaaa" {
+		t.Errorf("expected the only game to be picked but got %v, %v", game.GameId, ok)
+	}
+
+	several := []lichess.GameEvent{{GameId: "aaaaaaaa"}, {GameId: "bbbbbbbb"}}
+	received := make(chan UIInput, 1)
+	go func() { received <- <-uiState.Input }()
+	if _, ok := pickGame(uiState, several); ok {
+		t.Errorf("expected the player to choose among several games")
+	}
+	if input := <-received; input != ChooseGame {
+		t.Errorf("expected ChooseGame but got %v", input)
+	}
+
+	// the same list of games does not prompt the player again
+	if _, ok := pickGame(uiState, several); ok {
+		t.Errorf("expected the player to choose among several games")
+	}
+
+	uiState.SelectGame("bbbbbbbb")
+	if game, ok := pickGame(uiState, several); !ok || game.GameId != "bbbbbbbb" {
+		t.Errorf("expected the selected game to be picked but got %v, %v", game.GameId, ok)
+	}
+
+	uiState.SelectGame("cccccccc")
+	pickGame(uiState, single)
+	if uiState.SelectedGame() != "" {
+		t.Errorf("expected a stale selection to be cleared")
+	}
+}
+
+func TestFindValidMoveChess960SameSquares(t *testing.T) {
+	state := NewMainState(context.Background())
+	state.game.UpdateFromFindGame(lichess.GameEvent{FullID: "abcdefghijkl", GameId: "abcdefgh", Color: "white"})
+	// castling on the king side moves the king from f1 to g1, and the rook from g1 to f1
+	state.game.UpdateFromGameFull(lichess.GameFullEvent{
+		InitialFen: "bnrbqkrn/pppppppp/8/8/8/8/PPPPPPPP/BNRBQKRN w KQkq - 0 1",
+		Variant:    lichess.Variant{Key: "chess960"},
+		State:      lichess.GameStateEvent{Status: "started"},
+	})
+	if _, ok := state.Game().CastlingMoves()["f1g1"]; !ok {
+		t.Fatalf("expected f1g1 to be a castling move, got %v", state.Game().CastlingMoves())
+	}
+
+	inPosition := boardOf(state.Game().ChessGame().Position())
+	observe := func(board BoardState) string {
+		state.board = &Board{connected: true, state: board}
+		state.UpdateLitSquares()
+		move, _ := findValidMove(state)
+		return move
+	}
+
+	if move := observe(inPosition); move != "" {
+		t.Errorf("expected the board in the current position not to be a castle, got %s", move)
+	}
+	lifted := inPosition
+	lifted[chess.FileF][chess.Rank1] = chess.NoColor
+	if move := observe(lifted); move != "" {
+		t.Errorf("expected no move while the king is lifted, got %s", move)
+	}
+	if move := observe(inPosition); move != "f1g1" {
+		t.Errorf("expected the king and the rook to castle once lifted, got %q", move)
+	}
+}
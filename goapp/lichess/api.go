@@ -5,11 +5,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 )
 
 type secretFile struct {
@@ -20,38 +23,179 @@ type withType struct {
 	Type string `json:"type"`
 }
 
-func AbortGame(gameId string) {
-	_, err := lichessFetch(context.Background(), fmt.Sprintf("board/game/%s/abort", gameId), nil, "POST")
+// Calls that don't stream are given this timeout, unless the caller's context already has a deadline
+const DefaultTimeout = 10 * time.Second
+
+func withDefaultTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, DefaultTimeout)
+}
+
+// postAction sends a POST request whose response we don't need
+func postAction(ctx context.Context, path string, params map[string]string) error {
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+
+	body, err := lichessFetch(ctx, path, params, "POST")
+	if err != nil {
+		return err
+	}
+	return body.Close()
+}
+
+// send forwards an event to a channel, unless the context is cancelled first
+func send[T any](ctx context.Context, c chan T, value T) bool {
+	select {
+	case c <- value:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func AbortGame(ctx context.Context, gameId string) {
+	err := postAction(ctx, fmt.Sprintf("board/game/%s/abort", gameId), nil)
 	if err != nil {
 		log.Printf("Error aborting game: %v", err)
 	}
 }
 
-func DrawGame(gameId string) {
-	_, err := lichessFetch(context.Background(), fmt.Sprintf("board/game/%s/draw/yes", gameId), nil, "POST")
+// Draw offers or accepts a draw when accept is true, and declines the opponent's offer otherwise. Offering a draw also claims it when the position repeated three times
+func Draw(ctx context.Context, gameId string, accept bool) {
+	answer := "no"
+	if accept {
+		answer = "yes"
+	}
+	err := postAction(ctx, fmt.Sprintf("board/game/%s/draw/%s", gameId, answer), nil)
 	if err != nil {
 		log.Printf("Error drawing game: %v", err)
 	}
 }
 
-func CreateSeek(timeMinute, incrementSeconds string) *context.CancelFunc {
-	ctx, cancel := context.WithCancel(context.Background())
+// Takeback proposes or accepts a takeback when accept is true, and declines the opponent's proposal otherwise
+func Takeback(ctx context.Context, gameId string, accept bool) {
+	answer := "no"
+	if accept {
+		answer = "yes"
+	}
+	err := postAction(ctx, fmt.Sprintf("board/game/%s/takeback/%s", gameId, answer), nil)
+	if err != nil {
+		log.Printf("Error answering takeback: %v", err)
+	}
+}
+
+type RatingRange struct {
+	Min int
+	Max int
+	// When set, Min and Max are offsets from our own rating, e.g. -100 and 200
+	Relative bool
+}
+
+func (r RatingRange) IsSet() bool {
+	return r.Min != 0 || r.Max != 0
+}
+
+type SeekRequest struct {
+	Rated     bool
+	Time      int // minutes
+	Increment int // seconds
+	// Correspondence seeks only. Time and increment are ignored when set
+	Days        int
+	Variant     string
+	Color       string // "random", "white" or "black"
+	RatingRange RatingRange
+}
+
+// Speed estimates the speed lichess will assign to the seek, based on its time control
+func (r SeekRequest) Speed() GameSpeed {
+	if r.Days > 0 {
+		return Correspondence
+	}
+
+	estimated := r.Time*60 + 40*r.Increment
+	switch {
+	case estimated < 30:
+		return UltraBullet
+	case estimated < 180:
+		return Bullet
+	case estimated < 480:
+		return Blitz
+	case estimated < 1500:
+		return Rapid
+	default:
+		return Classical
+	}
+}
+
+func (r SeekRequest) String() string {
+	mode := "casual"
+	if r.Rated {
+		mode = "rated"
+	}
+	if r.Days > 0 {
+		return fmt.Sprintf("%d days %s", r.Days, mode)
+	}
+	return fmt.Sprintf("%d|%d %s", r.Time, r.Increment, mode)
+}
+
+// params builds the seek parameters. myRating is used to resolve relative rating ranges
+func (r SeekRequest) params(myRating int) map[string]string {
 	params := make(map[string]string)
-	params["increment"] = incrementSeconds
-	params["rated"] = "true"
+	params["rated"] = fmt.Sprintf("%t", r.Rated)
+	if r.Days > 0 {
+		params["days"] = fmt.Sprintf("%d", r.Days)
+	} else {
+		params["time"] = fmt.Sprintf("%d", r.Time)
+		params["increment"] = fmt.Sprintf("%d", r.Increment)
+	}
+	params["variant"] = r.Variant
+	if r.Variant == "" {
+		params["variant"] = "standard"
+	}
+	params["color"] = r.Color
+	if r.Color == "" {
+		params["color"] = "random"
+	}
 	params["ratingRange"] = ""
-	params["time"] = timeMinute
-	params["variant"] = "standard"
+	if r.RatingRange.IsSet() {
+		low, high := r.RatingRange.Min, r.RatingRange.Max
+		if r.RatingRange.Relative {
+			low, high = myRating+low, myRating+high
+		}
+		params["ratingRange"] = fmt.Sprintf("%d-%d", low, high)
+	}
+	return params
+}
+
+// CreateSeek creates a seek that lives until the returned function (or the parent context) cancels it. It returns nil if the seek could not be created
+func CreateSeek(parent context.Context, req SeekRequest) *context.CancelFunc {
+	ctx, cancel := context.WithCancel(parent)
+
+	myRating := 0
+	if req.RatingRange.IsSet() && req.RatingRange.Relative {
+		account, err := GetAccount(ctx)
+		if err != nil {
+			log.Printf("Error fetching our rating, seeking without rating range: %v", err)
+			req.RatingRange = RatingRange{}
+		} else {
+			perf, _ := account.Perfs.Perf(req.Speed())
+			myRating = perf.Rating
+		}
+	}
 
-	body, err := lichessFetch(ctx, "board/seek", params, "POST")
+	body, err := lichessFetch(ctx, "board/seek", req.params(myRating), "POST")
 	if err != nil {
 		log.Printf("Error creating seek: %v", err)
+		cancel()
+		return nil
 	}
 
 	// Stream the response in the background
 	go streamResponse(ctx, body)
 
-	log.Printf("%s|%s seek successfully created\n", timeMinute, incrementSeconds)
+	log.Printf("%s seek successfully created\n", req)
 	return &cancel
 }
 
@@ -81,44 +225,76 @@ func streamResponse(ctx context.Context, respBody io.ReadCloser) {
 	}
 }
 
-func ResignGame(gameId string) {
-	_, err := lichessFetch(context.Background(), fmt.Sprintf("board/game/%s/resign", gameId), nil, "POST")
+func ResignGame(ctx context.Context, gameId string) {
+	err := postAction(ctx, fmt.Sprintf("board/game/%s/resign", gameId), nil)
 	if err != nil {
 		log.Println("Error resigning game:", err)
 		return
 	}
 }
 
-func PlayMove(gameId string, move string) error {
+func PlayMove(ctx context.Context, gameId string, move string) error {
 	log.Printf("PLAYING MOVE %s on game %s", move, gameId)
-	_, err := lichessFetch(context.Background(), fmt.Sprintf("board/game/%s/move/%s", gameId, move), nil, "POST")
-	return err
+	return postAction(ctx, fmt.Sprintf("board/game/%s/move/%s", gameId, move), nil)
 }
 
-func FindPlayingGame(lichessGame *Game) error {
+// ListPlayingGames returns our ongoing games, ordered by lichess with the most urgent first
+func ListPlayingGames(ctx context.Context) ([]GameEvent, error) {
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+
 	params := make(map[string]string)
-	params["nb"] = "1"
-	body, err := lichessFetch(context.Background(), "account/playing", params, "GET")
+	params["nb"] = "50"
+	body, err := lichessFetch(ctx, "account/playing", params, "GET")
 	if err != nil {
-		return fmt.Errorf("error fetching playing games: %v", err)
+		return nil, fmt.Errorf("error fetching playing games: %w", err)
 	}
 	defer body.Close()
 	data, err := io.ReadAll(body)
 	if err != nil {
-		return fmt.Errorf("error reading response body: %v", err)
+		return nil, fmt.Errorf("error reading response body: %w", err)
 	}
 	var response FindPlayingGameResponse
 	err = json.Unmarshal(data, &response)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if len(response.NowPlaying) > 0 {
-		found := response.NowPlaying[0]
-		lichessGame.UpdateFromFindGame(found)
+	return response.NowPlaying, nil
+}
+
+// FindGame looks up one of our ongoing games by its 8 characters id, which can be missing from the list of playing games
+func FindGame(ctx context.Context, gameId string) (GameEvent, error) {
+	account, err := GetAccount(ctx)
+	if err != nil {
+		return GameEvent{}, err
+	}
+	export, err := ExportGame(ctx, gameId)
+	if err != nil {
+		return GameEvent{}, err
 	}
+	return export.GameEvent(account.ID)
+}
+
+// ParseGameID extracts the 8 characters game id from a lichess game URL, a game id or a full id
+func ParseGameID(input string) (string, error) {
+	id := strings.TrimSpace(input)
+	if i := strings.Index(id, "lichess.org/"); i >= 0 {
+		id = id[i+len("lichess.org/"):]
+	}
+	id, _, _ = strings.Cut(id, "/")
+	id, _, _ = strings.Cut(id, "#")
+	id, _, _ = strings.Cut(id, "?")
 
-	return nil
+	if len(id) != 8 && len(id) != 12 {
+		return "", fmt.Errorf("invalid game id %q", input)
+	}
+	for _, c := range id {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return "", fmt.Errorf("invalid game id %q", input)
+		}
+	}
+	return id[:8], nil
 }
 
 func readSecret() (string, error) {
@@ -145,9 +321,62 @@ func buildURLParams(params map[string]string) string {
 	return urlParams
 }
 
+// lichessFetch sends a request to the lichess API. Requests are held while we are rate limited, and retried when it is safe to do so
 func lichessFetch(ctx context.Context, path string, params map[string]string, method string) (io.ReadCloser, error) {
+	return lichessFetchAccept(ctx, path, params, method, "")
+}
+
+// lichessFetchAccept is lichessFetch for endpoints whose response format depends on the Accept header. Paths starting with a slash are relative to the site root instead of the API
+func lichessFetchAccept(ctx context.Context, path string, params map[string]string, method string, accept string) (io.ReadCloser, error) {
+	for attempt := 0; ; attempt++ {
+		if wait := rateLimitRemaining(); wait > 0 {
+			// the caller would give up before the ban is over
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+				return nil, fmt.Errorf("calling %s: %w for another %s", path, ErrRateLimited, wait.Round(time.Second))
+			}
+			log.Printf("Rate limited by lichess, waiting %s before calling %s", wait.Round(time.Second), path)
+			if err := sleepContext(ctx, wait); err != nil {
+				return nil, err
+			}
+		}
+
+		body, err := lichessFetchOnce(ctx, path, params, method, accept)
+		if err == nil {
+			return body, nil
+		}
+		if errors.Is(err, ErrRateLimited) {
+			setRateLimited()
+		}
+		if ctx.Err() != nil || attempt >= maxRetries || !shouldRetry(err, method) {
+			return nil, err
+		}
+
+		delay := retryDelay(attempt)
+		log.Printf("Request to %s failed (%v), retrying in %s", path, err, delay.Round(time.Millisecond))
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func sleepContext(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func lichessFetchOnce(ctx context.Context, path string, params map[string]string, method string, accept string) (io.ReadCloser, error) {
 
 	lichessURL := fmt.Sprintf("https://lichess.org/api/%s", path)
+	if strings.HasPrefix(path, "/") {
+		lichessURL = "https://lichess.org" + path
+	}
 	// Add query parameters to the URL
 	if method == "GET" && len(params) > 0 {
 		lichessURL += "?" + buildURLParams(params)
@@ -164,14 +393,14 @@ func lichessFetch(ctx context.Context, path string, params map[string]string, me
 		var body = []byte(buildURLParams(params))
 		req, err = http.NewRequest(method, lichessURL, bytes.NewBuffer(body))
 		if err != nil {
-			return nil, fmt.Errorf("error creating request: %v", err)
+			return nil, fmt.Errorf("error creating request: %w", err)
 		}
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	case "GET":
 		req, err = http.NewRequest(method, lichessURL, nil)
 		if err != nil {
-			return nil, fmt.Errorf("error creating request: %v", err)
+			return nil, fmt.Errorf("error creating request: %w", err)
 		}
 	default:
 		return nil, fmt.Errorf("unsupported method: %s", method)
@@ -179,7 +408,7 @@ func lichessFetch(ctx context.Context, path string, params map[string]string, me
 
 	apiToken, err := readSecret()
 	if err != nil {
-		return nil, fmt.Errorf("error reading secret: %v", err)
+		return nil, fmt.Errorf("error reading secret: %w", err)
 	}
 
 	// Use context
@@ -187,30 +416,84 @@ func lichessFetch(ctx context.Context, path string, params map[string]string, me
 
 	// Set headers
 	req.Header.Set("Authorization", "Bearer "+apiToken)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
 
 	// Send the request
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error sending request: %v", err)
+		return nil, fmt.Errorf("error sending request: %w", err)
 	}
 
 	// Check for errors
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("error: %s", resp.Status)
+		return nil, newAPIError(resp, path)
 	}
 
 	// Read the response body
 	return resp.Body, nil
 }
 
-func StreamGame(gameId string, chans *LichessEventChans) {
-	body, err := lichessFetch(context.Background(), fmt.Sprintf("board/game/stream/%s", gameId), nil, "GET")
+const maxStreamBackoff = 30 * time.Second
+
+// streamBackoff returns how long to wait before the given reconnection attempt (starting at 0)
+func streamBackoff(attempt int) time.Duration {
+	delay := 500 * time.Millisecond
+	for range attempt {
+		delay *= 2
+		if delay >= maxStreamBackoff {
+			return maxStreamBackoff
+		}
+	}
+	return delay
+}
+
+// StreamGame streams the game events until the game reaches a terminal status. Dropped connections are re-established with a backoff, and lichess sends a gameFull event on each reconnection
+func StreamGame(ctx context.Context, gameId string, chans *LichessEventChans) {
+	attempt := 0
+	for {
+		ended, receivedEvents, err := streamGameOnce(ctx, gameId, chans)
+		if ctx.Err() != nil {
+			log.Printf("Stopped streaming game %s", gameId)
+			return
+		}
+		if errors.Is(err, ErrNotFound) {
+			log.Printf("Game %s not found, stop streaming", gameId)
+			ended = true
+		}
+		if errors.Is(err, ErrUnauthorized) {
+			log.Printf("Lichess rejected our token, stop streaming game %s: %v", gameId, err)
+			send(ctx, chans.StreamFailed, err)
+			return
+		}
+		if ended {
+			send(ctx, chans.GameEnded, true)
+			return
+		}
+
+		if receivedEvents {
+			// the connection was healthy for a while, start over with a short delay
+			attempt = 0
+		}
+		delay := streamBackoff(attempt)
+		attempt++
+		log.Printf("Game stream for %s interrupted (%v). Reconnecting in %s", gameId, err, delay)
+		if sleepContext(ctx, delay) != nil {
+			return
+		}
+	}
+}
+
+// streamGameOnce reads the game stream until the connection drops. It returns whether the game has ended, and whether any event was received
+func streamGameOnce(ctx context.Context, gameId string, chans *LichessEventChans) (bool, bool, error) {
+	body, err := lichessFetch(ctx, fmt.Sprintf("board/game/stream/%s", gameId), nil, "GET")
 	if err != nil {
-		log.Fatalf("Error streaming game: %v", err)
-		return
+		return false, false, err
 	}
 	defer body.Close()
 
+	receivedEvents := false
 	scanner := bufio.NewScanner(body)
 	for scanner.Scan() {
 		line := scanner.Bytes()
@@ -224,6 +507,7 @@ func StreamGame(gameId string, chans *LichessEventChans) {
 			log.Printf("Error unmarshalling chat line: %v", err)
 			continue
 		}
+		receivedEvents = true
 
 		switch withType.Type {
 		case "chatLine":
@@ -233,7 +517,7 @@ func StreamGame(gameId string, chans *LichessEventChans) {
 				log.Printf("Error unmarshalling chat line: %v", err)
 				continue
 			}
-			chans.ChatChan <- chatLine
+			send(ctx, chans.ChatChan, chatLine)
 			continue
 		case "opponentGone":
 			var oppGone OpponentGoneEvent
@@ -242,7 +526,7 @@ func StreamGame(gameId string, chans *LichessEventChans) {
 				log.Printf("Error unmarshalling opponent gone event: %v", err)
 				continue
 			}
-			chans.OpponentGoneChan <- oppGone
+			send(ctx, chans.OpponentGoneChan, oppGone)
 			continue
 		case "gameState":
 			var gs GameStateEvent
@@ -251,7 +535,10 @@ func StreamGame(gameId string, chans *LichessEventChans) {
 				log.Printf("Error unmarshalling game state event: %v", err)
 				continue
 			}
-			chans.GameStateChan <- gs
+			send(ctx, chans.GameStateChan, gs)
+			if IsTerminalStatus(gs.Status) {
+				return true, receivedEvents, nil
+			}
 			continue
 		case "gameFull":
 			var gameFullEvent GameFullEvent
@@ -260,7 +547,10 @@ func StreamGame(gameId string, chans *LichessEventChans) {
 				log.Printf("Error unmarshalling game full event: %v", err)
 				continue
 			}
-			chans.GameStateChan <- gameFullEvent.State
+			send(ctx, chans.GameFullChan, gameFullEvent)
+			if IsTerminalStatus(gameFullEvent.State.Status) {
+				return true, receivedEvents, nil
+			}
 			continue
 		default:
 			log.Printf("Unknown event type: %s", withType.Type)
@@ -269,35 +559,121 @@ func StreamGame(gameId string, chans *LichessEventChans) {
 	}
 
 	if err := scanner.Err(); err != nil {
-		log.Fatalf("Error reading stream: %v", err)
-		return
+		return false, receivedEvents, fmt.Errorf("error reading stream: %w", err)
 	}
-
-	chans.GameEnded <- true
-
+	return false, receivedEvents, io.EOF
 }
 
-func ClaimVictory(gameId string) {
-	_, err := lichessFetch(context.Background(), fmt.Sprintf("board/game/%s/claim-victory", gameId), nil, "POST")
+func ClaimVictory(ctx context.Context, gameId string) {
+	err := postAction(ctx, fmt.Sprintf("board/game/%s/claim-victory", gameId), nil)
 	if err != nil {
 		log.Printf("Error claiming victory: %v", err)
 	}
 }
 
-func GetPlayer(username string) (*PlayerProfile, error) {
-	body, err := lichessFetch(context.Background(), fmt.Sprintf("user/%s", username), nil, "GET")
+func GetPlayer(ctx context.Context, username string) (*PlayerProfile, error) {
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+
+	body, err := lichessFetch(ctx, fmt.Sprintf("user/%s", username), nil, "GET")
 	if err != nil {
-		return nil, fmt.Errorf("error fetching player profile: %v", err)
+		return nil, fmt.Errorf("error fetching player profile: %w", err)
 	}
 	defer body.Close()
 	data, err := io.ReadAll(body)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response body: %v", err)
+		return nil, fmt.Errorf("error reading response body: %w", err)
 	}
 	var profile PlayerProfile
 	err = json.Unmarshal(data, &profile)
 	if err != nil {
-		return nil, fmt.Errorf("error unmarshalling player profile: %v", err)
+		return nil, fmt.Errorf("error unmarshalling player profile: %w", err)
 	}
 	return &profile, nil
 }
+
+// ExportGamePGN fetches a game in PGN, with the clock times of every move
+func ExportGamePGN(ctx context.Context, gameId string) (string, error) {
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+
+	body, err := lichessFetchAccept(ctx, fmt.Sprintf("/game/export/%s", gameId), map[string]string{"clocks": "true", "evals": "false"}, "GET", "application/x-chess-pgn")
+	if err != nil {
+		return "", fmt.Errorf("error exporting game: %w", err)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", fmt.Errorf("error reading response body: %w", err)
+	}
+	return string(data), nil
+}
+
+// GetPlayerStatus tells whether a player is online and playing
+func GetPlayerStatus(ctx context.Context, username string) (*PlayerStatus, error) {
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+
+	body, err := lichessFetch(ctx, "users/status", map[string]string{"ids": username}, "GET")
+	if err != nil {
+		return nil, fmt.Errorf("error fetching player status: %w", err)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+	var statuses []PlayerStatus
+	err = json.Unmarshal(data, &statuses)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling player status: %w", err)
+	}
+	if len(statuses) == 0 {
+		return nil, fmt.Errorf("no status for player %s: %w", username, ErrNotFound)
+	}
+	return &statuses[0], nil
+}
+
+// GetCrosstable fetches the head-to-head record of two players
+func GetCrosstable(ctx context.Context, user1, user2 string) (*Crosstable, error) {
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+
+	body, err := lichessFetch(ctx, fmt.Sprintf("crosstable/%s/%s", user1, user2), nil, "GET")
+	if err != nil {
+		return nil, fmt.Errorf("error fetching crosstable: %w", err)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+	var crosstable Crosstable
+	err = json.Unmarshal(data, &crosstable)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling crosstable: %w", err)
+	}
+	return &crosstable, nil
+}
+
+// ExportGame fetches a game as JSON, including the rating changes once the game is over
+func ExportGame(ctx context.Context, gameId string) (*GameExport, error) {
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+
+	body, err := lichessFetchAccept(ctx, fmt.Sprintf("/game/export/%s", gameId), nil, "GET", "application/json")
+	if err != nil {
+		return nil, fmt.Errorf("error exporting game: %w", err)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+	var export GameExport
+	err = json.Unmarshal(data, &export)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling game export: %w", err)
+	}
+	return &export, nil
+}
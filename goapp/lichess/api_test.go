@@ -1,6 +1,10 @@
 package lichess
 
-import "testing"
+import (
+	"context"
+	"testing"
+	"time"
+)
 
 func TestBuildURLParams(t *testing.T) {
 	// with nil
@@ -33,3 +37,133 @@ func TestBuildURLParams(t *testing.T) {
 	}
 
 }
+
+func TestChallengeRequestParams(t *testing.T) {
+	params := ChallengeRequest{ClockLimit: 900, ClockIncrement: 10}.params()
+
+	expected := map[string]string{
+		"rated":           "false",
+		"clock.limit":     "900",
+		"clock.increment": "10",
+		"color":           "random",
+		"variant":         "standard",
+	}
+	for key, value := range expected {
+		if params[key] != value {
+			t.Errorf("expected %s to be %s, but got %s", key, value, params[key])
+		}
+	}
+
+	params = ChallengeRequest{Rated: true, Color: "black", Variant: "chess960"}.params()
+	if params["rated"] != "true" || params["color"] != "black" || params["variant"] != "chess960" {
+		t.Errorf("expected explicit values to be kept, but got %v", params)
+	}
+}
+
+func TestSeekRequestParams(t *testing.T) {
+	params := SeekRequest{Rated: true, Time: 15, Increment: 10}.params(1800)
+	expected := map[string]string{
+		"rated":       "true",
+		"time":        "15",
+		"increment":   "10",
+		"variant":     "standard",
+		"color":       "random",
+		"ratingRange": "",
+	}
+	for key, value := range expected {
+		if params[key] != value {
+			t.Errorf("expected %s to be %s, but got %s", key, value, params[key])
+		}
+	}
+
+	// absolute rating range
+	params = SeekRequest{Time: 15, RatingRange: RatingRange{Min: 1500, Max: 1900}}.params(1800)
+	if params["ratingRange"] != "1500-1900" {
+		t.Errorf("expected absolute rating range 1500-1900, but got %s", params["ratingRange"])
+	}
+
+	// relative rating range
+	params = SeekRequest{Time: 15, RatingRange: RatingRange{Min: -100, Max: 200, Relative: true}}.params(1800)
+	if params["ratingRange"] != "1700-2000" {
+		t.Errorf("expected relative rating range 1700-2000, but got %s", params["ratingRange"])
+	}
+
+	// correspondence
+	params = SeekRequest{Days: 3, Time: 15, Increment: 10}.params(1800)
+	if params["days"] != "3" {
+		t.Errorf("expected days to be 3, but got %s", params["days"])
+	}
+	if _, ok := params["time"]; ok {
+		t.Errorf("expected correspondence seek to have no time")
+	}
+}
+
+func TestSeekRequestSpeed(t *testing.T) {
+	cases := map[GameSpeed]SeekRequest{
+		Bullet:         {Time: 1, Increment: 0},
+		Blitz:          {Time: 3, Increment: 2},
+		Rapid:          {Time: 15, Increment: 10},
+		Classical:      {Time: 30, Increment: 20},
+		Correspondence: {Days: 3},
+	}
+	for speed, req := range cases {
+		if req.Speed() != speed {
+			t.Errorf("expected %s to be %s, but got %s", req, speed, req.Speed())
+		}
+	}
+}
+
+func TestStreamBackoff(t *testing.T) {
+	if streamBackoff(0) != 500*time.Millisecond {
+		t.Errorf("expected first backoff to be 500ms, but got %s", streamBackoff(0))
+	}
+	if streamBackoff(2) != 2*time.Second {
+		t.Errorf("expected third backoff to be 2s, but got %s", streamBackoff(2))
+	}
+	if streamBackoff(100) != maxStreamBackoff {
+		t.Errorf("expected backoff to be capped at %s, but got %s", maxStreamBackoff, streamBackoff(100))
+	}
+}
+
+func TestWithDefaultTimeout(t *testing.T) {
+	ctx, cancel := withDefaultTimeout(context.Background())
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	if !ok || time.Until(deadline) > DefaultTimeout {
+		t.Errorf("expected a default deadline within %s", DefaultTimeout)
+	}
+
+	// an existing deadline is kept
+	parent, cancelParent := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelParent()
+	ctx, cancel = withDefaultTimeout(parent)
+	defer cancel()
+	deadline, _ = ctx.Deadline()
+	if time.Until(deadline) <= DefaultTimeout {
+		t.Errorf("expected the parent deadline to be kept")
+	}
+}
+
+func TestParseGameID(t *testing.T) {
+	valid := map[string]string{
+		"abcdefgh":                             "abcdefgh",
+		"abcdefghWXYZ":                         "abcdefgh",
+		" https://lichess.org/abcdefgh ":       "abcdefgh",
+		"https://lichess.org/abcdefgh/black":   "abcdefgh",
+		"lichess.org/abcdefghWXYZ":             "abcdefgh",
+		"https://lichess.org/abcdefgh#12":      "abcdefgh",
+		"https://lichess.org/abcdefgh?foo=bar": "abcdefgh",
+	}
+	for input, expected := range valid {
+		id, err := ParseGameID(input)
+		if err != nil || id != expected {
+			t.Errorf("expected %q to be parsed as %s, got %s (%v)", input, expected, id, err)
+		}
+	}
+
+	for _, input := range []string{"", "abc", "https://lichess.org/", "abcdefg!"} {
+		if _, err := ParseGameID(input); err == nil {
+			t.Errorf("expected %q to be invalid", input)
+		}
+	}
+}
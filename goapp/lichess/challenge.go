@@ -0,0 +1,192 @@
+package lichess
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+)
+
+func AcceptChallenge(ctx context.Context, challengeId string) error {
+	err := postAction(ctx, fmt.Sprintf("challenge/%s/accept", challengeId), nil)
+	if err != nil {
+		return fmt.Errorf("error accepting challenge: %w", err)
+	}
+	return nil
+}
+
+// DeclineChallenge declines an incoming challenge. The reason is one of lichess' decline reason keys (generic, later, tooFast, tooSlow, timeControl, rated, casual, standard, variant, noBot, onlyBot)
+func DeclineChallenge(ctx context.Context, challengeId, reason string) error {
+	params := make(map[string]string)
+	if reason != "" {
+		params["reason"] = reason
+	}
+	err := postAction(ctx, fmt.Sprintf("challenge/%s/decline", challengeId), params)
+	if err != nil {
+		return fmt.Errorf("error declining challenge: %w", err)
+	}
+	return nil
+}
+
+func GetAccount(ctx context.Context) (*Account, error) {
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+
+	body, err := lichessFetch(ctx, "account", nil, "GET")
+	if err != nil {
+		return nil, fmt.Errorf("error fetching account: %w", err)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+	var account Account
+	err = json.Unmarshal(data, &account)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling account: %w", err)
+	}
+	return &account, nil
+}
+
+// StreamEvents streams the incoming events of our account, and returns when the stream ends.
+func StreamEvents(ctx context.Context, chans *IncomingEventChans) error {
+	body, err := lichessFetch(ctx, "stream/event", nil, "GET")
+	if err != nil {
+		return fmt.Errorf("error streaming events: %w", err)
+	}
+	defer body.Close()
+
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var withType withType
+		err := json.Unmarshal(line, &withType)
+		if err != nil {
+			log.Printf("Error unmarshalling event: %v", err)
+			continue
+		}
+
+		switch withType.Type {
+		case "challenge", "challengeCanceled":
+			var evt ChallengeEvent
+			err := json.Unmarshal(line, &evt)
+			if err != nil {
+				log.Printf("Error unmarshalling challenge event: %v", err)
+				continue
+			}
+			if withType.Type == "challenge" {
+				send(ctx, chans.ChallengeChan, evt.Challenge)
+			} else {
+				send(ctx, chans.ChallengeCanceledChan, evt.Challenge)
+			}
+		case "gameStart", "gameFinish", "challengeDeclined":
+			// games are picked up by FindPlayingGame
+			continue
+		default:
+			log.Printf("Unknown event type: %s", withType.Type)
+		}
+	}
+
+	return scanner.Err()
+}
+
+type ChallengeRequest struct {
+	Rated          bool
+	ClockLimit     int    // seconds
+	ClockIncrement int    // seconds
+	Color          string // "random", "white" or "black"
+	Variant        string
+	RematchOf      string // id of the game this challenge is a rematch of
+}
+
+func (r ChallengeRequest) params() map[string]string {
+	params := make(map[string]string)
+	params["rated"] = fmt.Sprintf("%t", r.Rated)
+	params["clock.limit"] = fmt.Sprintf("%d", r.ClockLimit)
+	params["clock.increment"] = fmt.Sprintf("%d", r.ClockIncrement)
+	params["color"] = r.Color
+	if r.Color == "" {
+		params["color"] = "random"
+	}
+	params["variant"] = r.Variant
+	if r.Variant == "" {
+		params["variant"] = "standard"
+	}
+	if r.RematchOf != "" {
+		params["rematchOf"] = r.RematchOf
+	}
+	return params
+}
+
+type challengeDone struct {
+	Done string `json:"done"`
+}
+
+// ChallengeUser challenges a lichess user, and waits until the challenge is accepted, declined or canceled. It returns the final status of the challenge ("accepted", "declined" or "canceled")
+func ChallengeUser(ctx context.Context, username string, req ChallengeRequest) (string, error) {
+	params := req.params()
+	params["keepAliveStream"] = "true"
+
+	body, err := lichessFetch(ctx, fmt.Sprintf("challenge/%s", username), params, "POST")
+	if err != nil {
+		return "", fmt.Errorf("error challenging %s: %w", username, err)
+	}
+	defer body.Close()
+
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var done challengeDone
+		if err := json.Unmarshal(line, &done); err == nil && done.Done != "" {
+			log.Printf("Challenge to %s is %s", username, done.Done)
+			return done.Done, nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("error reading challenge stream: %w", err)
+	}
+	return "", fmt.Errorf("challenge stream ended without an answer from %s", username)
+}
+
+type aiChallengeResponse struct {
+	ID string `json:"id"`
+}
+
+// ChallengeAI starts a game against lichess' Stockfish at the given level (1 to 8), and returns the id of the new game
+func ChallengeAI(ctx context.Context, level int, req ChallengeRequest) (string, error) {
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+
+	params := req.params()
+	delete(params, "rated") // games against the AI are always casual
+	params["level"] = fmt.Sprintf("%d", level)
+
+	body, err := lichessFetch(ctx, "challenge/ai", params, "POST")
+	if err != nil {
+		return "", fmt.Errorf("error challenging the AI: %w", err)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", fmt.Errorf("error reading response body: %w", err)
+	}
+	var response aiChallengeResponse
+	err = json.Unmarshal(data, &response)
+	if err != nil {
+		return "", fmt.Errorf("error unmarshalling AI challenge: %w", err)
+	}
+	log.Printf("Started game %s against AI level %d", response.ID, level)
+	return response.ID, nil
+}
@@ -0,0 +1,312 @@
+package lichess
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// StartingFEN is the initial position of standard games
+const StartingFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+// castlingRooks holds the squares of the rooks that can still castle in a Chess960 game. notnil/chess only knows about standard castling, so Chess960 castling is handled here
+type castlingRooks map[chess.Square]bool
+
+// newChessGame replays the moves from the initial position of the game. Castling rooks are only tracked for Chess960 games, and are nil otherwise
+func newChessGame(initialFen, variant string, moves []string) (*chess.Game, castlingRooks, error) {
+	fen := initialFen
+	if fen == "" || fen == "startpos" {
+		fen = StartingFEN
+	}
+
+	var rooks castlingRooks
+	if variant == "chess960" {
+		var err error
+		if rooks, fen, err = parseCastlingRooks(fen); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	fenOption, err := chess.FEN(fen)
+	if err != nil {
+		return nil, nil, err
+	}
+	g := chess.NewGame(fenOption, chess.UseNotation(chess.UCINotation{}))
+	for _, move := range moves {
+		if move == "" {
+			continue
+		}
+		if g, err = applyMove(g, rooks, move); err != nil {
+			return nil, nil, fmt.Errorf("invalid move %s: %w", move, err)
+		}
+	}
+	return g, rooks, nil
+}
+
+// parseCastlingRooks reads the castling field of a Chess960 FEN, either as KQkq or as the files of the castling rooks (Shredder-FEN). The returned FEN has no castling rights, so notnil/chess never generates standard castling moves
+func parseCastlingRooks(fen string) (castlingRooks, string, error) {
+	fields := strings.Fields(fen)
+	if len(fields) != 6 {
+		return nil, "", fmt.Errorf("invalid fen %s", fen)
+	}
+	castling := fields[2]
+	fields[2] = "-"
+	fen = strings.Join(fields, " ")
+
+	fenOption, err := chess.FEN(fen)
+	if err != nil {
+		return nil, "", err
+	}
+	board := chess.NewGame(fenOption).Position().Board()
+
+	rooks := castlingRooks{}
+	for _, c := range castling {
+		switch {
+		case c == '-':
+		case c == 'K' || c == 'Q' || c == 'k' || c == 'q':
+			color, rank := chess.White, chess.Rank1
+			if c == 'k' || c == 'q' {
+				color, rank = chess.Black, chess.Rank8
+			}
+			king, ok := findKing(board, color, rank)
+			if !ok {
+				return nil, "", fmt.Errorf("invalid castling rights %s: no king on its first rank", castling)
+			}
+			// the outermost rook on the given side of the king
+			files, step := chess.FileH, -1
+			if c == 'Q' || c == 'q' {
+				files, step = chess.FileA, 1
+			}
+			for f := files; f != king.File(); f += chess.File(step) {
+				square := chess.NewSquare(f, rank)
+				if board.Piece(square) == chess.NewPiece(chess.Rook, color) {
+					rooks[square] = true
+					break
+				}
+			}
+		case c >= 'A' && c <= 'H':
+			rooks[chess.NewSquare(chess.File(c-'A'), chess.Rank1)] = true
+		case c >= 'a' && c <= 'h':
+			rooks[chess.NewSquare(chess.File(c-'a'), chess.Rank8)] = true
+		default:
+			return nil, "", fmt.Errorf("invalid castling rights %s", castling)
+		}
+	}
+	return rooks, fen, nil
+}
+
+func findKing(board *chess.Board, color chess.Color, rank chess.Rank) (chess.Square, bool) {
+	for f := chess.FileA; f <= chess.FileH; f++ {
+		square := chess.NewSquare(f, rank)
+		if board.Piece(square) == chess.NewPiece(chess.King, color) {
+			return square, true
+		}
+	}
+	return chess.NoSquare, false
+}
+
+// parseSquare reads a square in algebraic notation, such as e4
+func parseSquare(s string) (chess.Square, bool) {
+	if len(s) != 2 || s[0] < 'a' || s[0] > 'h' || s[1] < '1' || s[1] > '8' {
+		return chess.NoSquare, false
+	}
+	return chess.NewSquare(chess.File(s[0]-'a'), chess.Rank(s[1]-'1')), true
+}
+
+// applyMove plays a UCI move. Chess960 castling is written as the king taking its own rook, and returns a new game
+func applyMove(g *chess.Game, rooks castlingRooks, move string) (*chess.Game, error) {
+	if rooks == nil {
+		return g, g.MoveStr(move)
+	}
+	if len(move) < 4 {
+		return g, fmt.Errorf("invalid move %s", move)
+	}
+	from, okFrom := parseSquare(move[:2])
+	to, okTo := parseSquare(move[2:4])
+	if !okFrom || !okTo {
+		return g, fmt.Errorf("invalid move %s", move)
+	}
+
+	if rooks.isCastling(g.Position(), from, to) {
+		return castle(g, rooks, from, to)
+	}
+
+	moved := g.Position().Board().Piece(from)
+	if err := g.MoveStr(move); err != nil {
+		return g, err
+	}
+	if moved.Type() == chess.King {
+		rooks.remove(from.Rank())
+	}
+	// the rook moved, or was captured
+	delete(rooks, from)
+	delete(rooks, to)
+	return g, nil
+}
+
+// isCastling tells whether the king of the side to move takes one of its rooks that can still castle
+func (r castlingRooks) isCastling(pos *chess.Position, king, rook chess.Square) bool {
+	board := pos.Board()
+	return r[rook] &&
+		board.Piece(king) == chess.NewPiece(chess.King, pos.Turn()) &&
+		board.Piece(rook) == chess.NewPiece(chess.Rook, pos.Turn())
+}
+
+// remove forgets the castling rooks of a rank, once the king has moved
+func (r castlingRooks) remove(rank chess.Rank) {
+	for square := range r {
+		if square.Rank() == rank {
+			delete(r, square)
+		}
+	}
+}
+
+// castleTargets returns where the king and the rook land: on the g and f files when castling on the king side, on the c and d files otherwise
+func castleTargets(king, rook chess.Square) (chess.Square, chess.Square) {
+	if rook.File() > king.File() {
+		return chess.NewSquare(chess.FileG, king.Rank()), chess.NewSquare(chess.FileF, king.Rank())
+	}
+	return chess.NewSquare(chess.FileC, king.Rank()), chess.NewSquare(chess.FileD, king.Rank())
+}
+
+// castle plays a Chess960 castling move by building the resulting position. The history is lost, which doesn't matter for repetitions since castling can't be undone
+func castle(g *chess.Game, rooks castlingRooks, king, rook chess.Square) (*chess.Game, error) {
+	pos := g.Position()
+	kingTo, rookTo := castleTargets(king, rook)
+
+	squares := pos.Board().SquareMap()
+	kingPiece, rookPiece := squares[king], squares[rook]
+	delete(squares, king)
+	delete(squares, rook)
+	squares[kingTo] = kingPiece
+	squares[rookTo] = rookPiece
+
+	fields := strings.Fields(pos.String())
+	halfMoves, _ := strconv.Atoi(fields[4])
+	fullMoves, _ := strconv.Atoi(fields[5])
+	turn := "b"
+	if pos.Turn() == chess.Black {
+		turn = "w"
+		fullMoves++
+	}
+
+	fenOption, err := chess.FEN(fmt.Sprintf("%s %s - - %d %d", chess.NewBoard(squares).String(), turn, halfMoves+1, fullMoves))
+	if err != nil {
+		return g, err
+	}
+	rooks.remove(king.Rank())
+	return chess.NewGame(fenOption, chess.UseNotation(chess.UCINotation{})), nil
+}
+
+// castlingMoves returns the Chess960 castling moves of the side to move, with the position each of them leads to
+func castlingMoves(g *chess.Game, rooks castlingRooks) map[string]*chess.Position {
+	moves := map[string]*chess.Position{}
+	pos := g.Position()
+	board := pos.Board()
+
+	for rook := range rooks {
+		king, ok := findKing(board, pos.Turn(), rook.Rank())
+		if !ok || !rooks.isCastling(pos, king, rook) {
+			continue
+		}
+
+		// every square travelled by the king and the rook must be empty, except for themselves
+		kingTo, rookTo := castleTargets(king, rook)
+		low := min(king.File(), kingTo.File(), rook.File(), rookTo.File())
+		high := max(king.File(), kingTo.File(), rook.File(), rookTo.File())
+		free := true
+		for f := low; f <= high; f++ {
+			square := chess.NewSquare(f, rook.Rank())
+			if square != king && square != rook && board.Piece(square) != chess.NoPiece {
+				free = false
+			}
+		}
+		if !free {
+			continue
+		}
+
+		// the king can't castle out of, through or into check. The castling rook doesn't shield the king, as it moves too
+		safe := true
+		for f := min(king.File(), kingTo.File()); f <= max(king.File(), kingTo.File()); f++ {
+			if attacked(board, chess.NewSquare(f, rook.Rank()), pos.Turn().Other(), king, rook) {
+				safe = false
+			}
+		}
+		if !safe {
+			continue
+		}
+
+		// castle on a copy, as castling forgets the rooks
+		copied := castlingRooks{}
+		for square := range rooks {
+			copied[square] = true
+		}
+		if castled, err := castle(g, copied, king, rook); err == nil {
+			moves[king.String()+rook.String()] = castled.Position()
+		}
+	}
+	return moves
+}
+
+var (
+	knightJumps   = [][2]int{{1, 2}, {2, 1}, {2, -1}, {1, -2}, {-1, -2}, {-2, -1}, {-2, 1}, {-1, 2}}
+	kingSteps     = [][2]int{{1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}, {0, -1}, {1, -1}}
+	lineSteps     = [][2]int{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+	diagonalSteps = [][2]int{{1, 1}, {-1, 1}, {-1, -1}, {1, -1}}
+)
+
+// attacked tells whether a piece of the given color attacks the square. The squares to ignore are seen as empty
+func attacked(board *chess.Board, square chess.Square, by chess.Color, ignore ...chess.Square) bool {
+	// pieceAt returns the piece at the given offset from the square, and false when it is off the board
+	pieceAt := func(df, dr int) (chess.Piece, bool) {
+		f, r := int(square.File())+df, int(square.Rank())+dr
+		if f < 0 || f > 7 || r < 0 || r > 7 {
+			return chess.NoPiece, false
+		}
+		target := chess.NewSquare(chess.File(f), chess.Rank(r))
+		if slices.Contains(ignore, target) {
+			return chess.NoPiece, true
+		}
+		return board.Piece(target), true
+	}
+	jumps := func(steps [][2]int, pieceType chess.PieceType) bool {
+		for _, step := range steps {
+			if piece, _ := pieceAt(step[0], step[1]); piece == chess.NewPiece(pieceType, by) {
+				return true
+			}
+		}
+		return false
+	}
+	slides := func(steps [][2]int, pieceType chess.PieceType) bool {
+		for _, step := range steps {
+			for distance := 1; ; distance++ {
+				piece, ok := pieceAt(step[0]*distance, step[1]*distance)
+				if !ok {
+					break
+				}
+				if piece == chess.NoPiece {
+					continue
+				}
+				if piece == chess.NewPiece(pieceType, by) || piece == chess.NewPiece(chess.Queen, by) {
+					return true
+				}
+				break
+			}
+		}
+		return false
+	}
+
+	// pawns attack diagonally towards the opponent
+	forward := 1
+	if by == chess.Black {
+		forward = -1
+	}
+	return jumps([][2]int{{-1, -forward}, {1, -forward}}, chess.Pawn) ||
+		jumps(knightJumps, chess.Knight) ||
+		jumps(kingSteps, chess.King) ||
+		slides(lineSteps, chess.Rook) ||
+		slides(diagonalSteps, chess.Bishop)
+}
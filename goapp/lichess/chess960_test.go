@@ -0,0 +1,147 @@
+package lichess
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/notnil/chess"
+)
+
+// king on e1, rooks on b1 and g1
+const chess960FEN = "nrbqkbrn/pppppppp/8/8/8/8/PPPPPPPP/NRBQKBRN w KQkq - 0 1"
+
+func TestParseCastlingRooks(t *testing.T) {
+	rooks, fen, err := parseCastlingRooks(chess960FEN)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, square := range []chess.Square{chess.B1, chess.G1, chess.B8, chess.G8} {
+		if !rooks[square] {
+			t.Errorf("expected %s to be a castling rook", square)
+		}
+	}
+	if fen != "nrbqkbrn/pppppppp/8/8/8/8/PPPPPPPP/NRBQKBRN w - - 0 1" {
+		t.Errorf("expected castling rights to be removed from the fen, got %s", fen)
+	}
+
+	// Shredder-FEN names the files of the rooks
+	rooks, _, err = parseCastlingRooks("nrbqkbrn/pppppppp/8/8/8/8/PPPPPPPP/NRBQKBRN w Gb - 0 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rooks) != 2 || !rooks[chess.G1] || !rooks[chess.B8] {
+		t.Errorf("expected g1 and b8 to be the castling rooks, got %v", rooks)
+	}
+
+	if _, _, err := parseCastlingRooks("nrbqkbrn w KQkq"); err == nil {
+		t.Errorf("expected an invalid fen to fail")
+	}
+}
+
+func TestChess960Castling(t *testing.T) {
+	moves := []string{"g2g3", "g7g6", "h1g3"}
+	if _, _, err := newChessGame(chess960FEN, "chess960", moves); err == nil {
+		t.Errorf("expected a knight to be unable to jump on its own pawn")
+	}
+
+	moves = []string{"g2g3", "g7g6", "f1h3", "f8h6"}
+	g, rooks, err := newChessGame(chess960FEN, "chess960", moves)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	castling := castlingMoves(g, rooks)
+	position, ok := castling["e1g1"]
+	if !ok || len(castling) != 1 {
+		t.Fatalf("expected white to castle on the king side only, got %v", castling)
+	}
+
+	g, err = applyMove(g, rooks, "e1g1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	board := g.Position().Board()
+	if board.Piece(chess.G1) != chess.WhiteKing || board.Piece(chess.F1) != chess.WhiteRook || board.Piece(chess.E1) != chess.NoPiece {
+		t.Errorf("expected the king on g1 and the rook on f1, got %s", board.String())
+	}
+	if g.Position().Turn() != chess.Black {
+		t.Errorf("expected black to move after castling")
+	}
+	if g.Position().Board().String() != position.Board().String() {
+		t.Errorf("expected castling to lead to %s, got %s", position.Board().String(), g.Position().Board().String())
+	}
+	if rooks[chess.B1] || rooks[chess.G1] || !rooks[chess.G8] {
+		t.Errorf("expected white to lose its castling rights only, got %v", rooks)
+	}
+
+	// the game goes on after castling
+	if g, err = applyMove(g, rooks, "e8g8"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if g, err = applyMove(g, rooks, "d2d4"); err != nil {
+		t.Errorf("expected the game to go on after castling: %v", err)
+	}
+}
+
+func TestChess960CastlingThroughCheck(t *testing.T) {
+	cases := []struct {
+		fen      string
+		expected []string
+	}{
+		{"4k3/8/8/8/8/8/8/1R2K1R1 w KQ - 0 1", []string{"e1b1", "e1g1"}},
+		// f1 is attacked
+		{"4kr2/8/8/8/8/8/8/1R2K1R1 w KQ - 0 1", []string{"e1b1"}},
+		{"4k3/8/8/8/8/8/6p1/1R2K1R1 w KQ - 0 1", []string{"e1b1"}},
+		// the king is in check
+		{"k3r3/8/8/8/8/8/8/1R2K1R1 w KQ - 0 1", []string{}},
+		// the king would land on g1, behind the castling rook
+		{"4k1r1/8/8/8/8/8/8/1R2K1R1 w KQ - 0 1", []string{"e1b1"}},
+		// the a1 rook attacks c1 once the b1 rook has moved
+		{"k7/8/8/8/8/8/8/rR2K1R1 w KQ - 0 1", []string{"e1g1"}},
+	}
+
+	for _, c := range cases {
+		g, rooks, err := newChessGame(c.fen, "chess960", nil)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", c.fen, err)
+		}
+		castling := castlingMoves(g, rooks)
+		moves := []string{}
+		for move := range castling {
+			moves = append(moves, move)
+		}
+		slices.Sort(moves)
+		if !slices.Equal(moves, c.expected) {
+			t.Errorf("expected castling moves %v in %s, got %v", c.expected, c.fen, moves)
+		}
+	}
+}
+
+func TestUpdateFromPosition(t *testing.T) {
+	g := NewGame()
+	g.UpdateFromFindGame(GameEvent{FullID: "abcdefghijkl", GameId: "abcdefgh", Color: "white"})
+
+	// black to move
+	g.UpdateFromGameFull(GameFullEvent{
+		InitialFen: "4k3/8/8/8/8/8/4P3/4K3 b - - 0 1",
+		Variant:    Variant{Key: "fromPosition"},
+		State:      GameStateEvent{Status: "started"},
+	})
+	if g.IsMyTurn() {
+		t.Errorf("expected black to move first")
+	}
+
+	g.Update(GameStateEvent{Status: "started", Moves: "e8d7"})
+	if !g.IsMyTurn() {
+		t.Errorf("expected white to move after black")
+	}
+	if g.ChessGame().Position().Board().Piece(chess.D7) != chess.BlackKing {
+		t.Errorf("expected the black king on d7")
+	}
+}
+
+func TestNewChessGameFromMovesError(t *testing.T) {
+	if _, err := NewChessGameFromMoves([]string{"e2e4", "e2e4"}); err == nil {
+		t.Errorf("expected an invalid move to fail")
+	}
+}
@@ -0,0 +1,115 @@
+package lichess
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrRateLimited  = errors.New("rate limited")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrNotFound     = errors.New("not found")
+	ErrBadMove      = errors.New("bad move")
+)
+
+// lichess asks clients to wait a full minute after receiving a 429
+const RateLimitWait = time.Minute
+
+const maxRetries = 3
+
+// APIError is returned for any non-200 response. It wraps one of the Err* values when the status is a known one
+type APIError struct {
+	StatusCode int
+	Message    string // error message sent by lichess, if any
+	kind       error
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("error: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("error: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.kind
+}
+
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+// newAPIError builds an APIError from a failed response, and consumes its body
+func newAPIError(resp *http.Response, path string) *APIError {
+	defer resp.Body.Close()
+
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	var body errorBody
+	if err := json.Unmarshal(data, &body); err == nil && body.Error != "" {
+		apiErr.Message = body.Error
+	} else {
+		apiErr.Message = strings.TrimSpace(string(data))
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		apiErr.kind = ErrRateLimited
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		apiErr.kind = ErrUnauthorized
+	case resp.StatusCode == http.StatusNotFound:
+		apiErr.kind = ErrNotFound
+	case resp.StatusCode == http.StatusBadRequest && strings.Contains(path, "/move/"):
+		apiErr.kind = ErrBadMove
+	}
+	return apiErr
+}
+
+// shouldRetry tells whether a failed request can be sent again. Rate limited requests were not processed, so they can always be retried. Other failures are only retried for idempotent requests
+func shouldRetry(err error, method string) bool {
+	if errors.Is(err, ErrRateLimited) {
+		return true
+	}
+	if method != "GET" {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500
+	}
+	// network errors
+	return true
+}
+
+// retryDelay returns a jittered exponential backoff for the given attempt (starting at 0)
+func retryDelay(attempt int) time.Duration {
+	base := time.Duration(1<<attempt) * time.Second
+	jitter := time.Duration(rand.Int64N(int64(base / 2)))
+	return base/2 + jitter
+}
+
+var rateLimit = struct {
+	until time.Time
+	mu    sync.Mutex
+}{}
+
+func setRateLimited() {
+	rateLimit.mu.Lock()
+	defer rateLimit.mu.Unlock()
+	rateLimit.until = time.Now().Add(RateLimitWait)
+}
+
+func rateLimitRemaining() time.Duration {
+	rateLimit.mu.Lock()
+	defer rateLimit.mu.Unlock()
+	return time.Until(rateLimit.until)
+}
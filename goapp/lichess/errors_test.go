@@ -0,0 +1,105 @@
+package lichess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func responseWith(status int, body string) *http.Response {
+	rec := httptest.NewRecorder()
+	rec.WriteHeader(status)
+	rec.WriteString(body)
+	return rec.Result()
+}
+
+func TestNewAPIError(t *testing.T) {
+	err := newAPIError(responseWith(http.StatusBadRequest, `{"error":"Not your turn, or game already over"}`), "board/game/abc/move/e2e4")
+	if !errors.Is(err, ErrBadMove) {
+		t.Errorf("expected a 400 on a move to be a bad move, got %v", err)
+	}
+	if err.Message != "Not your turn, or game already over" {
+		t.Errorf("expected lichess error message to be parsed, got %q", err.Message)
+	}
+
+	err = newAPIError(responseWith(http.StatusBadRequest, `{"error":"oops"}`), "board/seek")
+	if errors.Is(err, ErrBadMove) {
+		t.Errorf("expected a 400 outside of moves not to be a bad move")
+	}
+
+	cases := map[int]error{
+		http.StatusTooManyRequests: ErrRateLimited,
+		http.StatusUnauthorized:    ErrUnauthorized,
+		http.StatusNotFound:        ErrNotFound,
+	}
+	for status, expected := range cases {
+		err := newAPIError(responseWith(status, "plain text"), "account")
+		if !errors.Is(err, expected) {
+			t.Errorf("expected status %d to be %v, got %v", status, expected, err)
+		}
+		if err.Message != "plain text" {
+			t.Errorf("expected raw body to be kept as message, got %q", err.Message)
+		}
+	}
+
+	// wrapped errors are still recognised
+	wrapped := fmt.Errorf("error fetching account: %w", newAPIError(responseWith(http.StatusNotFound, ""), "account"))
+	if !errors.Is(wrapped, ErrNotFound) {
+		t.Errorf("expected wrapped error to be not found")
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	rateLimited := &APIError{StatusCode: http.StatusTooManyRequests, kind: ErrRateLimited}
+	serverError := &APIError{StatusCode: http.StatusBadGateway}
+	badMove := &APIError{StatusCode: http.StatusBadRequest, kind: ErrBadMove}
+	networkError := errors.New("connection reset")
+
+	if !shouldRetry(rateLimited, "POST") {
+		t.Errorf("expected rate limited POST to be retried")
+	}
+	if !shouldRetry(serverError, "GET") || shouldRetry(serverError, "POST") {
+		t.Errorf("expected server errors to be retried for GET only")
+	}
+	if !shouldRetry(networkError, "GET") || shouldRetry(networkError, "POST") {
+		t.Errorf("expected network errors to be retried for GET only")
+	}
+	if shouldRetry(badMove, "POST") {
+		t.Errorf("expected bad moves not to be retried")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	for attempt := range maxRetries {
+		base := time.Duration(1<<attempt) * time.Second
+		for range 20 {
+			delay := retryDelay(attempt)
+			if delay < base/2 || delay >= base {
+				t.Errorf("expected delay for attempt %d to be in [%s, %s), got %s", attempt, base/2, base, delay)
+			}
+		}
+	}
+}
+
+func TestRateLimitedCallWithDeadline(t *testing.T) {
+	// a 429 bans every call for a minute
+	setRateLimited()
+	defer func() {
+		rateLimit.mu.Lock()
+		rateLimit.until = time.Time{}
+		rateLimit.mu.Unlock()
+	}()
+
+	start := time.Now()
+	err := PlayMove(context.Background(), "abcdefgh", "e2e4")
+	if !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected the move to be rate limited, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the call to fail right away instead of waiting for its deadline, took %s", elapsed)
+	}
+}
@@ -2,6 +2,7 @@ package lichess
 
 import (
 	"log"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -18,10 +19,20 @@ type Game struct {
 	btime              int
 	clockUpdatedAt     time.Time
 	winner             string // "white" or "black"
+	status             string
 	moves              []string
 	chessGame          *chess.Game
 	opponentOffersDraw bool
+	myDrawOffer        bool // our draw offer is pending
+	opponentTakeback   bool // opponent proposes a takeback
+	winc               int
+	binc               int
 	speed              GameSpeed
+	clock              *GameClock
+	variant            string // "standard", "chess960" or "fromPosition"
+	initialFen         string
+	castlingRooks      castlingRooks
+	createdAt          time.Time // zero until lichess tells when the game started
 
 	mu sync.RWMutex
 }
@@ -40,6 +51,13 @@ func (g *Game) Speed() GameSpeed {
 	return g.speed
 }
 
+// Clock returns the initial time and increment of the game, or nil for games without a clock
+func (g *Game) Clock() *GameClock {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.clock
+}
+
 func (g *Game) ChessGame() *chess.Game {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
@@ -58,6 +76,20 @@ func (g *Game) FullID() string {
 	return g.fullID
 }
 
+// GameID returns the 8 characters id of the game, which doesn't include our secret
+func (g *Game) GameID() string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.gameId
+}
+
+// CreatedAt returns when the game started, or the zero time if lichess didn't tell
+func (g *Game) CreatedAt() time.Time {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.createdAt
+}
+
 func (g *Game) Wtime() int {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
@@ -88,6 +120,12 @@ func (g *Game) Moves() []string {
 	return g.moves
 }
 
+func (g *Game) Status() string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.status
+}
+
 func (g *Game) Winner() string {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
@@ -100,10 +138,41 @@ func (g *Game) OpponentOffersDraw() bool {
 	return g.opponentOffersDraw
 }
 
-func (g *Game) SetOpponentOffersDraw(val bool) {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-	g.opponentOffersDraw = val
+func (g *Game) MyDrawOfferPending() bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.myDrawOffer
+}
+
+// CanClaimDraw tells whether the position repeated three times, or fifty moves were played without capture nor pawn move
+func (g *Game) CanClaimDraw() bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	for _, method := range g.chessGame.EligibleDraws() {
+		if method == chess.ThreefoldRepetition || method == chess.FiftyMoveRule {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Game) OpponentProposesTakeback() bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.opponentTakeback
+}
+
+func (g *Game) Winc() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.winc
+}
+
+func (g *Game) Binc() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.binc
 }
 
 func (g *Game) Reset() {
@@ -111,6 +180,7 @@ func (g *Game) Reset() {
 	defer g.mu.Unlock()
 
 	g.speed = ""
+	g.clock = nil
 	g.fullID = ""
 	g.gameId = ""
 	g.color = ""
@@ -119,9 +189,17 @@ func (g *Game) Reset() {
 	g.btime = -1
 	g.moves = []string{}
 	g.winner = ""
+	g.status = ""
 	g.clockUpdatedAt = time.Now()
 	g.chessGame = chess.NewGame(chess.UseNotation(chess.UCINotation{}))
 	g.opponentOffersDraw = false
+	g.myDrawOffer = false
+	g.opponentTakeback = false
+	g.winc = 0
+	g.binc = 0
+	g.variant = ""
+	g.initialFen = ""
+	g.castlingRooks = nil
 }
 
 func (g *Game) UpdateFromFindGame(evt GameEvent) {
@@ -136,18 +214,72 @@ func (g *Game) UpdateFromFindGame(evt GameEvent) {
 	g.wtime = -1
 	g.btime = -1
 	g.opponentOffersDraw = false
+	g.myDrawOffer = false
+	g.opponentTakeback = false
 	g.speed = evt.Speed
+	g.variant = ""
+	g.initialFen = ""
+	g.castlingRooks = nil
+	g.createdAt = time.Time{}
+	g.chessGame = chess.NewGame(chess.UseNotation(chess.UCINotation{}))
+}
+
+// UpdateFromGameFull resynchronises the game with the full game description, which lichess sends whenever the stream (re)connects
+func (game *Game) UpdateFromGameFull(evt GameFullEvent) {
+	game.mu.Lock()
+	defer game.mu.Unlock()
+
+	if evt.Speed != "" {
+		game.speed = evt.Speed
+	}
+	if evt.CreatedAt > 0 {
+		game.createdAt = time.UnixMilli(evt.CreatedAt)
+	}
+	game.clock = evt.Clock
+
+	if evt.Variant.Key != game.variant || evt.InitialFen != game.initialFen {
+		chessGame, rooks, err := newChessGame(evt.InitialFen, evt.Variant.Key, nil)
+		if err != nil {
+			log.Printf("ERROR, unsupported initial position %s (%s): %v", evt.InitialFen, evt.Variant.Key, err)
+		} else {
+			// the moves are replayed from the new initial position
+			game.variant = evt.Variant.Key
+			game.initialFen = evt.InitialFen
+			game.chessGame = chessGame
+			game.castlingRooks = rooks
+			game.moves = []string{}
+		}
+	}
+	game.update(evt.State)
 }
 
 func (game *Game) Update(newStateEvt GameStateEvent) {
 	game.mu.Lock()
 	defer game.mu.Unlock()
 
+	game.update(newStateEvt)
+}
+
+func (game *Game) update(newStateEvt GameStateEvent) {
 	game.wtime = newStateEvt.Wtime
 	game.btime = newStateEvt.Btime
+	game.winc = newStateEvt.Winc
+	game.binc = newStateEvt.Binc
 	game.winner = newStateEvt.Winner
+	game.status = newStateEvt.Status
 	game.clockUpdatedAt = time.Now()
 
+	switch game.color {
+	case "white":
+		game.opponentOffersDraw = newStateEvt.Bdraw
+		game.myDrawOffer = newStateEvt.Wdraw
+		game.opponentTakeback = newStateEvt.Btakeback
+	case "black":
+		game.opponentOffersDraw = newStateEvt.Wdraw
+		game.myDrawOffer = newStateEvt.Bdraw
+		game.opponentTakeback = newStateEvt.Wtakeback
+	}
+
 	newMoves := []string{}
 	rawMoves := strings.SplitSeq(newStateEvt.Moves, " ")
 
@@ -158,38 +290,62 @@ func (game *Game) Update(newStateEvt GameStateEvent) {
 		}
 	}
 
-	// if the number of moves changed, we reset the draw offer
-	if len(newMoves) != len(game.moves) {
-		game.opponentOffersDraw = false
-	}
-
+	oldMoves := game.moves
 	game.moves = newMoves
 
-	if len(newMoves) == 0 {
+	switch {
+	case slices.Equal(oldMoves, newMoves):
+		// clock or offer update, the position did not change
 		return
+	case len(newMoves) == len(oldMoves)+1 && slices.Equal(oldMoves, newMoves[:len(oldMoves)]):
+		// Try to add last move to the chess game
+		lastMove := newMoves[len(newMoves)-1]
+		if chessGame, err := applyMove(game.chessGame, game.castlingRooks, lastMove); err == nil {
+			game.chessGame = chessGame
+			return
+		}
+		log.Printf("WARNING, creating a new chess game because we could not add the last move %s from %+v\n", lastMove, newMoves)
+	case len(newMoves) < len(oldMoves):
+		log.Printf("%d moves were taken back, now at %+v\n", len(oldMoves)-len(newMoves), newMoves)
+	default:
+		// Perhaps we were lacking behind => create a new game and attach it
+		log.Printf("WARNING, creating a new chess game from %+v\n", newMoves)
 	}
+	chessGame, rooks, err := newChessGame(game.initialFen, game.variant, newMoves)
+	if err != nil {
+		log.Printf("ERROR, could not replay the moves %+v: %v\n", newMoves, err)
+		return
+	}
+	game.chessGame = chessGame
+	game.castlingRooks = rooks
+}
 
-	lastMove := newMoves[len(newMoves)-1]
+func (g *Game) InitialFen() string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.initialFen
+}
 
-	// Try to add last move to the chess game
+func (g *Game) Variant() string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.variant
+}
 
-	if err := game.chessGame.MoveStr(lastMove); err == nil {
-		return
-	} else {
-		// Adding a single move failed. Perhaps we were lacking behind => create a new game and attach it
-		log.Printf("WARNING, creating a new chess game because we could not add the last move %s from %+v\n", lastMove, newMoves)
-		game.chessGame = NewChessGameFromMoves(newMoves)
-	}
+// CastlingMoves returns the Chess960 castling moves available to the side to move, and the position each of them leads to. Standard castling is a regular king move
+func (g *Game) CastlingMoves() map[string]*chess.Position {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
 
+	if g.castlingRooks == nil {
+		return nil
+	}
+	return castlingMoves(g.chessGame, g.castlingRooks)
 }
 
+// CurrentTurn is read from the position, as games from a position can start with black to move
 func (game *Game) CurrentTurn() chess.Color {
-	moveLen := len(game.Moves())
-	if moveLen%2 == 0 {
-		return chess.White
-	} else {
-		return chess.Black
-	}
+	return game.ChessGame().Position().Turn()
 }
 
 func (game *Game) IsMyTurn() bool {
@@ -205,6 +361,10 @@ func (game *Game) IsMyTurn() bool {
 }
 
 func NewStubGame(moves []string) *Game {
+	chessGame, err := NewChessGameFromMoves(moves)
+	if err != nil {
+		log.Printf("invalid stub game: %v", err)
+	}
 
 	return &Game{
 		fullID:             "fake",
@@ -212,22 +372,15 @@ func NewStubGame(moves []string) *Game {
 		color:              "black",
 		opponent:           &Opponent{},
 		moves:              moves,
-		chessGame:          NewChessGameFromMoves(moves),
+		chessGame:          chessGame,
 		opponentOffersDraw: true,
 	}
 }
 
-func NewChessGameFromMoves(moves []string) *chess.Game {
-	g := chess.NewGame(chess.UseNotation(chess.UCINotation{}))
-	for _, move := range moves {
-		if move == "" {
-			continue
-		}
-		if err := g.MoveStr(move); err != nil {
-			log.Fatalf("invalid move %s", move)
-		}
-	}
-	return g
+// NewChessGameFromMoves replays the moves of a standard game
+func NewChessGameFromMoves(moves []string) (*chess.Game, error) {
+	g, _, err := newChessGame("", "standard", moves)
+	return g, err
 }
 
 // IsValidMove checks if the move is valid and returns a boolean indicating if the move is valid, and a boolean indicating if the move is a promotion
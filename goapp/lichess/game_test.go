@@ -165,3 +165,115 @@ func TestIsValidPromotions(t *testing.T) {
 	}
 
 }
+
+func TestUpdateFromGameFull(t *testing.T) {
+	g := NewStubGame([]string{"e2e4"})
+
+	// e.g. after a reconnection, we missed a few moves
+	g.UpdateFromGameFull(GameFullEvent{
+		Speed: Rapid,
+		Clock: &GameClock{Initial: 900000, Increment: 10000},
+		State: GameStateEvent{Status: "started", Moves: "e2e4 e7e5 g1f3"},
+	})
+
+	expected := []string{"e2e4", "e7e5", "g1f3"}
+	if !reflect.DeepEqual(expected, g.Moves()) {
+		t.Errorf("expected Moves to be %v, got %v", expected, g.Moves())
+	}
+	if len(g.ChessGame().Moves()) != 3 {
+		t.Errorf("expected chess game to have 3 moves, got %d", len(g.ChessGame().Moves()))
+	}
+	if g.Speed() != Rapid {
+		t.Errorf("expected speed to be rapid, got %s", g.Speed())
+	}
+	if clock := g.Clock(); clock == nil || clock.Initial != 900000 || clock.Increment != 10000 {
+		t.Errorf("expected clock to be 15+10, got %+v", clock)
+	}
+}
+
+func TestUpdateOffersFromGameState(t *testing.T) {
+	g := NewGame()
+	g.UpdateFromFindGame(GameEvent{FullID: "abcdefghijkl", GameId: "abcdefgh", Color: "white"})
+
+	g.Update(GameStateEvent{Status: "started", Moves: "e2e4", Winc: 10000, Binc: 5000, Bdraw: true})
+	if !g.OpponentOffersDraw() {
+		t.Errorf("expected black's draw offer to be the opponent's")
+	}
+	if g.OpponentProposesTakeback() {
+		t.Errorf("expected no takeback proposal")
+	}
+	if g.Winc() != 10000 || g.Binc() != 5000 {
+		t.Errorf("expected increments 10000/5000, got %d/%d", g.Winc(), g.Binc())
+	}
+
+	// our own offers are not the opponent's
+	g.Update(GameStateEvent{Status: "started", Moves: "e2e4", Wdraw: true, Wtakeback: true})
+	if g.OpponentOffersDraw() || g.OpponentProposesTakeback() {
+		t.Errorf("expected white's offers not to be the opponent's")
+	}
+
+	g.Update(GameStateEvent{Status: "started", Moves: "e2e4 e7e5", Btakeback: true})
+	if g.OpponentOffersDraw() {
+		t.Errorf("expected draw offer to be cleared")
+	}
+	if !g.OpponentProposesTakeback() {
+		t.Errorf("expected black's takeback proposal to be the opponent's")
+	}
+}
+
+func TestUpdateWithTakeback(t *testing.T) {
+	g := NewStubGame([]string{"e2e4", "e7e5", "g1f3"})
+
+	// one move taken back
+	g.Update(GameStateEvent{Status: "started", Moves: "e2e4 e7e5"})
+	if len(g.ChessGame().Moves()) != 2 {
+		t.Errorf("expected chess game to have 2 moves after the takeback, got %d", len(g.ChessGame().Moves()))
+	}
+
+	// back to the starting position
+	g.Update(GameStateEvent{Status: "started", Moves: ""})
+	if len(g.ChessGame().Moves()) != 0 {
+		t.Errorf("expected chess game to be back to the starting position, got %d moves", len(g.ChessGame().Moves()))
+	}
+	if g.CurrentTurn() != chess.White {
+		t.Errorf("expected white to play after taking everything back")
+	}
+
+	// The last move could be applied to the current position, yet two moves were taken back
+	g = NewStubGame([]string{"g1f3", "g8f6", "f3g1", "f6g8"})
+	g.Update(GameStateEvent{Status: "started", Moves: "g1f3 g8f6"})
+	if g.ChessGame().Position().Board().Piece(chess.F3) != chess.WhiteKnight {
+		t.Errorf("expected knight to be back on f3, got position %s", g.ChessGame().FEN())
+	}
+
+	// repeating the same state keeps the position
+	g.Update(GameStateEvent{Status: "started", Moves: "g1f3 g8f6", Wdraw: true})
+	if len(g.ChessGame().Moves()) != 2 {
+		t.Errorf("expected a state without new moves to keep the chess game, got %d moves", len(g.ChessGame().Moves()))
+	}
+}
+
+func TestDrawOffersAndClaims(t *testing.T) {
+	g := NewGame()
+	g.UpdateFromFindGame(GameEvent{FullID: "abcdefghijkl", GameId: "abcdefgh", Color: "black"})
+
+	g.Update(GameStateEvent{Status: "started", Moves: "g1f3", Bdraw: true})
+	if !g.MyDrawOfferPending() {
+		t.Errorf("expected black's draw offer to be ours")
+	}
+	if g.OpponentOffersDraw() {
+		t.Errorf("expected no draw offer from the opponent")
+	}
+	if g.CanClaimDraw() {
+		t.Errorf("expected no draw to claim after one move")
+	}
+
+	// knights dance until the starting position repeats three times
+	g.Update(GameStateEvent{Status: "started", Moves: "g1f3 g8f6 f3g1 f6g8 g1f3 g8f6 f3g1 f6g8"})
+	if g.MyDrawOfferPending() {
+		t.Errorf("expected our draw offer to be gone")
+	}
+	if !g.CanClaimDraw() {
+		t.Errorf("expected threefold repetition to be claimable")
+	}
+}
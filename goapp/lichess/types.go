@@ -1,6 +1,11 @@
 package lichess
 
-import "log"
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
 
 type GameSpeed string
 
@@ -18,27 +23,93 @@ type FindPlayingGameResponse struct {
 }
 
 type PlayerPerf struct {
-	Prov bool `json:"prov"`
+	Games  int  `json:"games"`
+	Rating int  `json:"rating"`
+	Prov   bool `json:"prov"`
 }
 type PlayerPerfs struct {
-	Rapid     PlayerPerf `json:"rapid"`
-	Classical PlayerPerf `json:"classical"`
-}
-type PlayerProfile struct {
-	Perfs PlayerPerfs `json:"perfs"`
+	UltraBullet    PlayerPerf `json:"ultraBullet"`
+	Bullet         PlayerPerf `json:"bullet"`
+	Blitz          PlayerPerf `json:"blitz"`
+	Rapid          PlayerPerf `json:"rapid"`
+	Classical      PlayerPerf `json:"classical"`
+	Correspondence PlayerPerf `json:"correspondence"`
 }
 
-func (p *PlayerProfile) IsProvisional(speed GameSpeed) bool {
-
+func (p PlayerPerfs) Perf(speed GameSpeed) (PlayerPerf, bool) {
 	switch speed {
-	case Classical:
-		return p.Perfs.Classical.Prov
+	case UltraBullet:
+		return p.UltraBullet, true
+	case Bullet:
+		return p.Bullet, true
+	case Blitz:
+		return p.Blitz, true
 	case Rapid:
-		return p.Perfs.Rapid.Prov
+		return p.Rapid, true
+	case Classical:
+		return p.Classical, true
+	case Correspondence:
+		return p.Correspondence, true
 	}
+	return PlayerPerf{}, false
+}
 
-	log.Printf("unknown cadency %s", speed)
-	return false
+type PlayerProfile struct {
+	ID           string      `json:"id"`
+	Username     string      `json:"username"`
+	Title        string      `json:"title"` // GM, IM, BOT...
+	Profile      PlayerBio   `json:"profile"`
+	Perfs        PlayerPerfs `json:"perfs"`
+	Count        PlayerCount `json:"count"`
+	CreatedAt    int64       `json:"createdAt"` // milliseconds since epoch
+	SeenAt       int64       `json:"seenAt"`    // milliseconds since epoch
+	Playing      string      `json:"playing"`   // URL of the game being played, if any
+	Disabled     bool        `json:"disabled"`  // the account is closed
+	TosViolation bool        `json:"tosViolation"`
+}
+
+type PlayerBio struct {
+	Flag     string `json:"flag"` // country code
+	Location string `json:"location"`
+}
+
+type PlayerCount struct {
+	All  int `json:"all"`
+	Win  int `json:"win"`
+	Loss int `json:"loss"`
+	Draw int `json:"draw"`
+}
+
+type PlayerStatus struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Online  bool   `json:"online"`
+	Playing bool   `json:"playing"`
+}
+
+// Crosstable is the head-to-head record of two players
+type Crosstable struct {
+	Users   map[string]float64 `json:"users"` // score by user id
+	NbGames int                `json:"nbGames"`
+}
+
+// Score returns the score of a player against the other one. Draws count for half a point
+func (c *Crosstable) Score(userID string) float64 {
+	return c.Users[strings.ToLower(userID)]
+}
+
+// Created returns the account creation date
+func (p *PlayerProfile) Created() time.Time {
+	return time.UnixMilli(p.CreatedAt)
+}
+
+func (p *PlayerProfile) IsProvisional(speed GameSpeed) bool {
+	perf, ok := p.Perfs.Perf(speed)
+	if !ok {
+		log.Printf("unknown cadency %s", speed)
+		return false
+	}
+	return perf.Prov
 }
 
 type GameEvent struct {
@@ -48,21 +119,41 @@ type GameEvent struct {
 	Fen      string    `json:"fen"`
 	Opponent Opponent  `json:"opponent"`
 	Speed    GameSpeed `json:"speed"`
+	Rated    bool      `json:"rated"`
+	IsMyTurn bool      `json:"isMyTurn"`
+	LastMove string    `json:"lastMove"`
 }
 
 type Opponent struct {
 	ID       string `json:"id"`
 	Username string `json:"username"`
 	Rating   int    `json:"rating"`
+	AI       int    `json:"ai"` // Stockfish level, when playing the computer
+}
+
+// IsTerminalStatus tells whether a game status means the game is over. Only "created" and "started" games are ongoing
+func IsTerminalStatus(status string) bool {
+	switch status {
+	case "", "created", "started":
+		return false
+	default:
+		return true
+	}
 }
 
 type GameStateEvent struct {
-	Type   string `json:"type"`
-	Wtime  int    `json:"wtime"`
-	Btime  int    `json:"btime"`
-	Status string `json:"status"`
-	Winner string `json:"winner"` // "white" or "black"
-	Moves  string `json:"moves"`
+	Type      string `json:"type"`
+	Wtime     int    `json:"wtime"`
+	Btime     int    `json:"btime"`
+	Winc      int    `json:"winc"`
+	Binc      int    `json:"binc"`
+	Status    string `json:"status"`
+	Winner    string `json:"winner"` // "white" or "black"
+	Moves     string `json:"moves"`
+	Wdraw     bool   `json:"wdraw"`     // white is offering a draw
+	Bdraw     bool   `json:"bdraw"`     // black is offering a draw
+	Wtakeback bool   `json:"wtakeback"` // white is proposing a takeback
+	Btakeback bool   `json:"btakeback"` // black is proposing a takeback
 }
 
 type ChatLineEvent struct {
@@ -78,18 +169,32 @@ type OpponentGoneEvent struct {
 	ClaimWinInSeconds int    `json:"claimWinInSeconds"`
 }
 
+type GameClock struct {
+	Initial   int `json:"initial"`   // milliseconds
+	Increment int `json:"increment"` // milliseconds
+}
+
 type GameFullEvent struct {
 	Type  string         `json:"type"`
+	ID    string         `json:"id"`
 	State GameStateEvent `json:"state"`
 	Color string         `json:"color"`
+	Clock *GameClock     `json:"clock"`
 	Speed GameSpeed      `json:"speed"`
+	// "startpos" for games starting from the standard position
+	InitialFen string  `json:"initialFen"`
+	Variant    Variant `json:"variant"`
+	CreatedAt  int64   `json:"createdAt"` // milliseconds since epoch
 }
 
 type LichessEventChans struct {
 	ChatChan         chan ChatLineEvent
 	OpponentGoneChan chan OpponentGoneEvent
 	GameStateChan    chan GameStateEvent
+	GameFullChan     chan GameFullEvent
 	GameEnded        chan bool
+	// the stream stopped on an error that reconnecting won't fix, e.g. a revoked token
+	StreamFailed chan error
 }
 
 func NewLichessEventChans() *LichessEventChans {
@@ -97,6 +202,131 @@ func NewLichessEventChans() *LichessEventChans {
 		ChatChan:         make(chan ChatLineEvent),
 		OpponentGoneChan: make(chan OpponentGoneEvent),
 		GameStateChan:    make(chan GameStateEvent),
+		GameFullChan:     make(chan GameFullEvent),
 		GameEnded:        make(chan bool),
+		StreamFailed:     make(chan error),
+	}
+}
+
+type ChallengePlayer struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Rating      int    `json:"rating"`
+	Title       string `json:"title"`
+	Provisional bool   `json:"provisional"`
+	Online      bool   `json:"online"`
+}
+
+type Variant struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+}
+
+type TimeControl struct {
+	Type        string `json:"type"` // "clock", "correspondence" or "unlimited"
+	Limit       int    `json:"limit"`
+	Increment   int    `json:"increment"`
+	DaysPerTurn int    `json:"daysPerTurn"`
+	Show        string `json:"show"`
+}
+
+type Challenge struct {
+	ID          string           `json:"id"`
+	URL         string           `json:"url"`
+	Status      string           `json:"status"`
+	Challenger  ChallengePlayer  `json:"challenger"`
+	DestUser    *ChallengePlayer `json:"destUser"`
+	Variant     Variant          `json:"variant"`
+	Rated       bool             `json:"rated"`
+	Speed       GameSpeed        `json:"speed"`
+	TimeControl TimeControl      `json:"timeControl"`
+	Color       string           `json:"color"` // "white", "black" or "random"
+}
+
+type ChallengeEvent struct {
+	Type      string    `json:"type"`
+	Challenge Challenge `json:"challenge"`
+}
+
+type Account struct {
+	ID       string      `json:"id"`
+	Username string      `json:"username"`
+	Perfs    PlayerPerfs `json:"perfs"`
+}
+
+type IncomingEventChans struct {
+	ChallengeChan         chan Challenge
+	ChallengeCanceledChan chan Challenge
+}
+
+func NewIncomingEventChans() *IncomingEventChans {
+	return &IncomingEventChans{
+		ChallengeChan:         make(chan Challenge),
+		ChallengeCanceledChan: make(chan Challenge),
+	}
+}
+
+type ExportPlayer struct {
+	User struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"user"`
+	Rating     int  `json:"rating"`
+	RatingDiff *int `json:"ratingDiff"` // only set for rated games
+}
+
+type GameExport struct {
+	ID        string    `json:"id"`
+	Rated     bool      `json:"rated"`
+	Speed     GameSpeed `json:"speed"`
+	Status    string    `json:"status"`
+	CreatedAt int64     `json:"createdAt"` // milliseconds since epoch
+	Winner    string    `json:"winner"`
+	Moves     string    `json:"moves"` // SAN
+	Players   struct {
+		White ExportPlayer `json:"white"`
+		Black ExportPlayer `json:"black"`
+	} `json:"players"`
+}
+
+// GameEvent describes the game as one of the ongoing games of the given user, or fails if the game is over or the user doesn't play it
+func (e *GameExport) GameEvent(userID string) (GameEvent, error) {
+	if IsTerminalStatus(e.Status) {
+		return GameEvent{}, fmt.Errorf("game %s is over", e.ID)
+	}
+
+	color := ""
+	switch strings.ToLower(userID) {
+	case e.Players.White.User.ID:
+		color = "white"
+	case e.Players.Black.User.ID:
+		color = "black"
+	default:
+		return GameEvent{}, fmt.Errorf("you don't play game %s", e.ID)
+	}
+
+	opponent := e.Player("white")
+	if color == "white" {
+		opponent = e.Player("black")
+	}
+	return GameEvent{
+		FullID: e.ID,
+		GameId: e.ID,
+		Color:  color,
+		Opponent: Opponent{
+			ID:       opponent.User.ID,
+			Username: opponent.User.Name,
+			Rating:   opponent.Rating,
+		},
+		Speed: e.Speed,
+		Rated: e.Rated,
+	}, nil
+}
+
+// Player returns the export of the player with the given color ("white" or "black")
+func (e *GameExport) Player(color string) ExportPlayer {
+	if color == "black" {
+		return e.Players.Black
 	}
+	return e.Players.White
 }
@@ -34,3 +34,59 @@ func TestPlayerProfileType(t *testing.T) {
 	}
 
 }
+
+func TestIsTerminalStatus(t *testing.T) {
+	for _, status := range []string{"", "created", "started"} {
+		if IsTerminalStatus(status) {
+			t.Errorf("expected %q not to be terminal", status)
+		}
+	}
+	for _, status := range []string{"aborted", "mate", "resign", "stalemate", "timeout", "draw", "outoftime", "cheat", "noStart", "variantEnd"} {
+		if !IsTerminalStatus(status) {
+			t.Errorf("expected %q to be terminal", status)
+		}
+	}
+}
+
+func TestCrosstable(t *testing.T) {
+	input := `{"users":{"neio":201.5,"thibault":144.5},"nbGames":346}`
+
+	var crosstable Crosstable
+	if err := json.Unmarshal([]byte(input), &crosstable); err != nil {
+		t.Fatalf("Failed to unmarshal Crosstable: %v", err)
+	}
+	if crosstable.NbGames != 346 {
+		t.Errorf("Expected 346 games, got %d", crosstable.NbGames)
+	}
+	if score := crosstable.Score("Neio"); score != 201.5 {
+		t.Errorf("Expected score to be 201.5, got %v", score)
+	}
+	if score := crosstable.Score("someone"); score != 0 {
+		t.Errorf("Expected unknown player to score 0, got %v", score)
+	}
+}
+
+func TestGameExportGameEvent(t *testing.T) {
+	input := `{"id":"q7ZvsdUF","rated":true,"speed":"correspondence","status":"started","players":{"white":{"user":{"name":"Neio","id":"neio"},"rating":2015},"black":{"user":{"name":"thibault","id":"thibault"},"rating":1742}}}`
+
+	var export GameExport
+	if err := json.Unmarshal([]byte(input), &export); err != nil {
+		t.Fatalf("Failed to unmarshal GameExport: %v", err)
+	}
+
+	game, err := export.GameEvent("Thibault")
+	if err != nil {
+		t.Fatalf("Expected thibault to play the game, got %v", err)
+	}
+	if game.GameId != "q7ZvsdUF" || game.Color != "black" || game.Opponent.Username != "Neio" || game.Opponent.Rating != 2015 || game.Speed != Correspondence {
+		t.Errorf("Unexpected game %+v", game)
+	}
+
+	if _, err := export.GameEvent("someone"); err == nil {
+		t.Errorf("Expected someone else's game to be rejected")
+	}
+	export.Status = "mate"
+	if _, err := export.GameEvent("neio"); err == nil {
+		t.Errorf("Expected a finished game to be rejected")
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aherve/eChess/goapp/lichess"
+	"github.com/notnil/chess"
+)
+
+// LocalGameSettings describe a game played without lichess, which the backend starts when it is requested from the UI
+type LocalGameSettings interface {
+	// Play starts the game, and returns when it is over
+	Play(state *MainState)
+	// Rematch returns the settings of the next game. color is our color in the last game
+	Rematch(color string) LocalGameSettings
+}
+
+// localGame is a game played without lichess. It keeps the moves and the clock, and sends them as lichess events so the game is followed just like a lichess game
+type localGame struct {
+	event         lichess.GameEvent
+	clockSettings ClockSettings
+
+	boardMoves chan string
+	commands   chan string   // "resign", "abort" or "draw"
+	done       chan struct{} // closed when the game is over
+
+	// only accessed from run
+	chessGame *chess.Game
+	moves     []string
+	clock     *Clock
+}
+
+// turnFunc waits for the next move, and returns the status and winner if the game ended instead
+type turnFunc func(ctx context.Context) (string, string)
+
+func newLocalGame(event lichess.GameEvent, clockSettings ClockSettings) *localGame {
+	return &localGame{
+		event:         event,
+		clockSettings: clockSettings,
+		boardMoves:    make(chan string),
+		commands:      make(chan string),
+		done:          make(chan struct{}),
+		chessGame:     chess.NewGame(chess.UseNotation(chess.UCINotation{})),
+		moves:         []string{},
+	}
+}
+
+func (g *localGame) ID() string {
+	return g.event.FullID
+}
+
+// GameEvent describes the game as lichess lists ongoing games
+func (g *localGame) GameEvent() lichess.GameEvent {
+	return g.event
+}
+
+// ListGames returns the game until it is over
+func (g *localGame) ListGames(ctx context.Context) ([]lichess.GameEvent, error) {
+	select {
+	case <-g.done:
+		return []lichess.GameEvent{}, nil
+	default:
+		return []lichess.GameEvent{g.event}, nil
+	}
+}
+
+func (g *localGame) FindGame(ctx context.Context, gameID string) (lichess.GameEvent, error) {
+	games, _ := g.ListGames(ctx)
+	for _, game := range games {
+		if game.GameId == gameID {
+			return game, nil
+		}
+	}
+	return lichess.GameEvent{}, fmt.Errorf("game %s is not being played", gameID)
+}
+
+// PlayMove sends the move made on the board
+func (g *localGame) PlayMove(ctx context.Context, gameID string, move string) error {
+	select {
+	case g.boardMoves <- move:
+		return nil
+	case <-g.done:
+		return ErrMoveRejected
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (g *localGame) Resign(ctx context.Context, gameID string) {
+	g.command("resign")
+}
+
+// Abort is only possible before each side has moved
+func (g *localGame) Abort(ctx context.Context, gameID string) {
+	g.command("abort")
+}
+
+func (g *localGame) Draw(ctx context.Context, gameID string, accept bool) {
+	if accept {
+		g.command("draw")
+	}
+}
+
+func (g *localGame) Takeback(ctx context.Context, gameID string, accept bool) {
+	log.Println("Takebacks are not available in offline games")
+}
+
+func (g *localGame) ClaimVictory(ctx context.Context, gameID string) {}
+
+func (g *localGame) Seek(ctx context.Context, req lichess.SeekRequest) *context.CancelFunc {
+	log.Println("Seeking is not available during an offline game")
+	return nil
+}
+
+func (g *localGame) Offline() bool {
+	return true
+}
+
+// command sends a resign, abort or draw request to the running game
+func (g *localGame) command(command string) {
+	select {
+	case g.commands <- command:
+	case <-g.done:
+		log.Printf("Command %s ignored, the game is over", command)
+	}
+}
+
+func (g *localGame) turn() string {
+	if g.chessGame.Position().Turn() == chess.White {
+		return "white"
+	}
+	return "black"
+}
+
+// flag fires when the side to move runs out of time
+func (g *localGame) flag() <-chan time.Time {
+	return time.After(g.clock.Remaining(g.chessGame.Position().Turn(), time.Now()))
+}
+
+// apply plays a move, presses the clock, and returns the time spent on the move
+func (g *localGame) apply(move string) (time.Duration, error) {
+	if err := g.chessGame.MoveStr(move); err != nil {
+		return 0, err
+	}
+	g.moves = append(g.moves, move)
+	return g.clock.Press(time.Now()), nil
+}
+
+func (g *localGame) state(status, winner string) lichess.GameStateEvent {
+	wtime, btime := g.clock.Millis(time.Now())
+	increment := int(g.clockSettings.Increment.Milliseconds())
+	return lichess.GameStateEvent{
+		Type:   "gameState",
+		Moves:  strings.Join(g.moves, " "),
+		Wtime:  wtime,
+		Btime:  btime,
+		Winc:   increment,
+		Binc:   increment,
+		Status: status,
+		Winner: winner,
+	}
+}
+
+// outcome returns the lichess status and winner once the game is over on the board
+func (g *localGame) outcome() (string, string) {
+	winner := ""
+	switch g.chessGame.Outcome() {
+	case chess.NoOutcome:
+		return "", ""
+	case chess.WhiteWon:
+		winner = "white"
+	case chess.BlackWon:
+		winner = "black"
+	}
+
+	switch g.chessGame.Method() {
+	case chess.Checkmate:
+		return "mate", winner
+	case chess.Stalemate:
+		return "stalemate", winner
+	default:
+		return "draw", winner
+	}
+}
+
+func opposite(color string) string {
+	if color == "white" {
+		return "black"
+	}
+	return "white"
+}
+
+// run starts the clock, and plays turns until the game ends or ctx is cancelled. It returns the status and winner of the game, or an empty status if it was left
+func (g *localGame) run(ctx context.Context, chans *lichess.LichessEventChans, playTurn turnFunc) (string, string) {
+	defer close(g.done)
+
+	g.clock = NewClock(g.clockSettings, time.Now())
+	select {
+	case <-ctx.Done():
+		return "", ""
+	case chans.GameFullChan <- lichess.GameFullEvent{
+		Type:  "gameFull",
+		ID:    g.ID(),
+		State: g.state("started", ""),
+		Clock: &lichess.GameClock{
+			Initial:   int(g.clockSettings.Initial.Milliseconds()),
+			Increment: int(g.clockSettings.Increment.Milliseconds()),
+		},
+		Speed:      g.event.Speed,
+		InitialFen: "startpos",
+		Variant:    lichess.Variant{Key: "standard", Name: "Standard"},
+		CreatedAt:  time.Now().UnixMilli(),
+	}:
+	}
+
+	for {
+		status, winner := g.outcome()
+		if status == "" {
+			status, winner = playTurn(ctx)
+		}
+		if ctx.Err() != nil {
+			return "", ""
+		}
+		if status != "" {
+			log.Printf("Offline game %s ended: %s %s", g.ID(), status, winner)
+			if sendState(ctx, chans, g.state(status, winner)) {
+				chans.GameEnded <- true
+			}
+			return status, winner
+		}
+		if !sendState(ctx, chans, g.state("started", "")) {
+			return "", ""
+		}
+	}
+}
+
+// sendState sends the state to the game follower, and returns false if the game was left
+func sendState(ctx context.Context, chans *lichess.LichessEventChans, state lichess.GameStateEvent) bool {
+	select {
+	case chans.GameStateChan <- state:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -1,35 +1,70 @@
 package main
 
 import (
+	"context"
 	"sync"
 	"time"
 
+	"github.com/aherve/eChess/goapp/archive"
 	"github.com/aherve/eChess/goapp/lichess"
 	"github.com/notnil/chess"
 )
 
 type MainState struct {
-	board         *Board
-	boardNotifs   chan bool
-	candidateMove *CandidateMove
-	game          *lichess.Game
-	litSquares    map[int8]bool
-	uIState       *UIState
+	backend        GameBackend
+	board          *Board
+	boardNotifs    chan bool
+	candidateMove  *CandidateMove
+	config         *Config
+	correspondence *CorrespondenceTracker
+	archive        *archive.Archive // nil when the archive could not be opened
+	replay         *Replay          // archived game being replayed on the board, if any
+	ctx            context.Context
+	game           *lichess.Game
+	litSquares     map[int8]bool
+	uIState        *UIState
+	// set when the physical board has to be brought back to the game position (e.g. after a takeback) before moves are detected again
+	awaitingSync bool
+	// illegal move shown on the board, and when it was first detected
+	illegalMove  string
+	illegalSince time.Time
+	// some Chess960 castles leave the same squares occupied: they are only detected once a piece was lifted from the position
+	liftPlies int  // moves of the position being watched
+	synced    bool // the board matched the position
+	lifted    bool // the board left the position after it matched
 
 	mu sync.RWMutex
 }
 
-func NewMainState() *MainState {
+// NewMainState creates the application state. Every lichess call made on behalf of the state is cancelled with ctx
+func NewMainState(ctx context.Context) *MainState {
 	return &MainState{
-		board:         NewBoard(),
-		boardNotifs:   make(chan bool),
-		game:          lichess.NewGame(),
-		litSquares:    map[int8]bool{},
-		uIState:       NewUIState(),
-		candidateMove: NewCandidateMove(),
+		backend:        LichessBackend{},
+		board:          NewBoard(),
+		boardNotifs:    make(chan bool),
+		config:         NewConfig(),
+		correspondence: NewCorrespondenceTracker(),
+		ctx:            ctx,
+		game:           lichess.NewGame(),
+		litSquares:     map[int8]bool{},
+		uIState:        NewUIState(),
+		candidateMove:  NewCandidateMove(),
 	}
 }
 
+// Backend returns the backend of the current game. It is lichess, unless an offline game is being played
+func (s *MainState) Backend() GameBackend {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.backend
+}
+
+func (s *MainState) SetBackend(backend GameBackend) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backend = backend
+}
+
 func (s *MainState) Board() *Board {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -46,6 +81,96 @@ func (s *MainState) ResetLitSquares() {
 	s.board.sendLEDCommand(s.litSquares)
 }
 
+func (s *MainState) Archive() *archive.Archive {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.archive
+}
+
+func (s *MainState) SetArchive(a *archive.Archive) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.archive = a
+}
+
+func (s *MainState) Replay() *Replay {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.replay
+}
+
+func (s *MainState) SetReplay(r *Replay) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replay = r
+}
+
+// RefreshLEDs lights the squares where the board differs from the position being played or replayed
+func (s *MainState) RefreshLEDs() {
+	s.UpdateLitSquares()
+	s.Board().sendLEDCommand(s.LitSquares())
+}
+
+func (s *MainState) AwaitingSync() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.awaitingSync
+}
+
+func (s *MainState) SetAwaitingSync(val bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.awaitingSync = val
+}
+
+// IllegalMove returns the illegal move made on the board, once it stayed long enough not to be a piece sliding through squares
+func (s *MainState) IllegalMove() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.illegalMove == "" || time.Since(s.illegalSince) < PlayDelay {
+		return ""
+	}
+	return s.illegalMove
+}
+
+func (s *MainState) SetIllegalMove(move string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if move != s.illegalMove {
+		s.illegalMove = move
+		s.illegalSince = time.Now()
+	}
+}
+
+// TrackLift follows the board against the game after the given number of moves, and returns whether the board is back in the position after a piece was lifted from it
+func (s *MainState) TrackLift(plies int, inSync bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if plies != s.liftPlies {
+		s.liftPlies = plies
+		s.synced = false
+		s.lifted = false
+	}
+	if !inSync {
+		s.lifted = s.synced
+		return false
+	}
+	s.synced = true
+	return s.lifted
+}
+
+// ResetLift forgets the lifts seen in the current position
+func (s *MainState) ResetLift() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.synced = false
+	s.lifted = false
+}
+
 func (s *MainState) BoardNotifs() chan bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -58,6 +183,30 @@ func (s *MainState) CandidateMove() *CandidateMove {
 	return s.candidateMove
 }
 
+func (s *MainState) Correspondence() *CorrespondenceTracker {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.correspondence
+}
+
+func (s *MainState) Config() *Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config
+}
+
+func (s *MainState) SetConfig(config *Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = config
+}
+
+func (s *MainState) Context() context.Context {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ctx
+}
+
 func (s *MainState) Game() *lichess.Game {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -79,6 +228,9 @@ func (s *MainState) UIState() *UIState {
 func (state *MainState) UpdateLitSquares() {
 	boardState := state.Board().State()
 	chessGameBoard := state.Game().ChessGame().Position().Board()
+	if replay := state.Replay(); replay != nil && state.Game().FullID() == "" {
+		chessGameBoard = replay.Position().Board()
+	}
 
 	state.mu.Lock()
 	defer state.mu.Unlock()
@@ -122,6 +274,26 @@ func (state *MainState) PlayEndSequence() {
 
 }
 
+// PlayIncidentSequence blinks the squares of an arbiter incident, then shows the lit squares again
+func (state *MainState) PlayIncidentSequence(squares []chess.Square) {
+	period := 150 * time.Millisecond
+	localEmptyState := map[int8]bool{}
+
+	localLitState := map[int8]bool{}
+	for _, square := range squares {
+		localLitState[int8(square)] = true
+	}
+
+	for range 5 {
+		state.Board().sendLEDCommand(localLitState)
+		time.Sleep(period)
+		state.Board().sendLEDCommand(localEmptyState)
+		time.Sleep(period)
+	}
+
+	state.Board().sendLEDCommand(state.LitSquares())
+}
+
 func (state *MainState) PlayStartSequence() {
 
 	period := 20 * time.Millisecond
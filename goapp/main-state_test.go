@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/aherve/eChess/goapp/lichess"
 	"github.com/notnil/chess"
@@ -37,7 +39,7 @@ func BenchmarkUpdateLitSquares(b *testing.B) {
 
 func manyMoveStub() *MainState {
 
-	s := NewMainState()
+	s := NewMainState(context.Background())
 
 	moves := []string{"e2e4", "e7e5"}
 	for range 100 {
@@ -62,3 +64,24 @@ func manyMoveStub() *MainState {
 
 	return s
 }
+
+func TestIllegalMove(t *testing.T) {
+	state := NewMainState(context.Background())
+
+	// a piece sliding through squares is not flagged
+	state.SetIllegalMove("e2e5")
+	if move := state.IllegalMove(); move != "" {
+		t.Errorf("expected the illegal move to be flagged later, got %s", move)
+	}
+
+	time.Sleep(PlayDelay)
+	state.SetIllegalMove("e2e5")
+	if move := state.IllegalMove(); move != "e2e5" {
+		t.Errorf("expected e2e5 to be flagged, got %q", move)
+	}
+
+	state.SetIllegalMove("")
+	if move := state.IllegalMove(); move != "" {
+		t.Errorf("expected the flag to be cleared, got %s", move)
+	}
+}
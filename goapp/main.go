@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"time"
 
+	"github.com/aherve/eChess/goapp/archive"
 	"github.com/aherve/eChess/goapp/lichess"
 )
 
@@ -17,8 +19,24 @@ func main() {
 	defer f.Close()
 	log.SetOutput(f)
 
+	// Cancelled on shutdown, which stops any pending lichess call
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	// Init state
-	state := NewMainState()
+	state := NewMainState(ctx)
+
+	config, err := LoadConfig(ConfigFile)
+	if err != nil {
+		log.Fatalf("error loading %s: %v", ConfigFile, err)
+	}
+	state.SetConfig(config)
+
+	if gameArchive, err := archive.Open(ArchiveDir); err != nil {
+		log.Printf("error opening the game archive in %s, games will not be archived: %v", ArchiveDir, err)
+	} else {
+		state.SetArchive(gameArchive)
+	}
 
 	debug := os.Getenv("DEBUG") == "true"
 	if debug {
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aherve/eChess/goapp/lichess"
+)
+
+// OpponentProfile gathers what we know about the opponent of the current game. Status and Crosstable are nil when they could not be fetched
+type OpponentProfile struct {
+	Player     *lichess.PlayerProfile
+	Status     *lichess.PlayerStatus
+	Crosstable *lichess.Crosstable
+	MyID       string
+}
+
+// handleOpponent fetches the opponent profile for the UI, and applies the auto-abort rules
+func handleOpponent(state *MainState) {
+	game := state.Game()
+	if game.Opponent().AI > 0 || state.Backend().Offline() {
+		return
+	}
+
+	ctx := state.Context()
+	opponentName := game.Opponent().Username
+	player, err := lichess.GetPlayer(ctx, opponentName)
+	if err != nil {
+		log.Printf("Error fetching opponent info: %v", err)
+		return
+	}
+	profile := &OpponentProfile{Player: player}
+
+	myRating := 0
+	account, err := lichess.GetAccount(ctx)
+	if err != nil {
+		log.Printf("Error fetching our account, the rating gap and the head to head record are unavailable: %v", err)
+	} else if perf, ok := account.Perfs.Perf(game.Speed()); ok {
+		myRating = perf.Rating
+	}
+
+	// abort before loading the rest of the profile, while the opponent may still be waiting for our first move.
+	// Correspondence games are resumed long after they started
+	if game.Speed() != lichess.Correspondence {
+		applyAbortRules(state, player, myRating)
+	}
+
+	if account != nil {
+		profile.MyID = account.ID
+		if profile.Crosstable, err = lichess.GetCrosstable(ctx, account.ID, player.ID); err != nil {
+			log.Printf("Error fetching head to head record: %v", err)
+		}
+	}
+	if profile.Status, err = lichess.GetPlayerStatus(ctx, player.ID); err != nil {
+		log.Printf("Error fetching opponent status: %v", err)
+	}
+
+	state.UIState().SetOpponentProfile(profile)
+	state.UIState().Input <- OpponentLoaded
+}
+
+var profileSpeeds = []lichess.GameSpeed{lichess.Bullet, lichess.Blitz, lichess.Rapid, lichess.Classical, lichess.Correspondence}
+
+// String describes the opponent for the profile panel
+func (p *OpponentProfile) String() string {
+	player := p.Player
+	lines := []string{}
+
+	name := player.Username
+	if player.Title != "" {
+		name = player.Title + " " + name
+	}
+	if player.Profile.Flag != "" {
+		name += fmt.Sprintf(" [%s]", player.Profile.Flag)
+	}
+	if p.Status != nil {
+		switch {
+		case p.Status.Playing:
+			name += " · playing"
+		case p.Status.Online:
+			name += " · online"
+		default:
+			name += " · offline"
+		}
+	}
+	if player.Disabled {
+		name += " · closed account"
+	}
+	if player.TosViolation {
+		name += " · TOS violation"
+	}
+	lines = append(lines, name)
+
+	joined := fmt.Sprintf("Joined %s", player.Created().Format("Jan 2006"))
+	if player.Count.All > 0 {
+		joined += fmt.Sprintf(" · %d games (+%d -%d =%d)", player.Count.All, player.Count.Win, player.Count.Loss, player.Count.Draw)
+	}
+	lines = append(lines, joined)
+
+	ratings := []string{}
+	for _, speed := range profileSpeeds {
+		perf, _ := player.Perfs.Perf(speed)
+		if perf.Games == 0 {
+			continue
+		}
+		rating := fmt.Sprintf("%s %d", speed, perf.Rating)
+		if perf.Prov {
+			rating += "?"
+		}
+		ratings = append(ratings, fmt.Sprintf("%s (%d)", rating, perf.Games))
+	}
+	if len(ratings) > 0 {
+		lines = append(lines, strings.Join(ratings, " · "))
+	}
+
+	if p.Crosstable != nil && p.Crosstable.NbGames > 0 {
+		lines = append(lines, fmt.Sprintf("Head to head: %s - %s in %d games",
+			formatScore(p.Crosstable.Score(p.MyID)), formatScore(p.Crosstable.Score(player.ID)), p.Crosstable.NbGames))
+	} else if p.Crosstable != nil {
+		lines = append(lines, "First game against each other")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// formatScore writes half points as ½
+func formatScore(score float64) string {
+	whole := int(score)
+	if score-float64(whole) < 0.5 {
+		return fmt.Sprintf("%d", whole)
+	}
+	if whole == 0 {
+		return "½"
+	}
+	return fmt.Sprintf("%d½", whole)
+}
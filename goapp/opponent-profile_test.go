@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aherve/eChess/goapp/lichess"
+)
+
+func TestOpponentProfileString(t *testing.T) {
+	profile := &OpponentProfile{
+		Player: &lichess.PlayerProfile{
+			ID:        "magnus",
+			Username:  "Magnus",
+			Title:     "GM",
+			Profile:   lichess.PlayerBio{Flag: "NO"},
+			CreatedAt: time.Date(2020, 3, 15, 0, 0, 0, 0, time.UTC).UnixMilli(),
+			Count:     lichess.PlayerCount{All: 10, Win: 6, Loss: 3, Draw: 1},
+			Perfs: lichess.PlayerPerfs{
+				Blitz: lichess.PlayerPerf{Games: 8, Rating: 2900},
+				Rapid: lichess.PlayerPerf{Games: 2, Rating: 2700, Prov: true},
+			},
+		},
+		Status:     &lichess.PlayerStatus{ID: "magnus", Online: true},
+		Crosstable: &lichess.Crosstable{Users: map[string]float64{"me": 0.5, "magnus": 2.5}, NbGames: 3},
+		MyID:       "me",
+	}
+
+	text := profile.String()
+	for _, expected := range []string{
+		"GM Magnus [NO] · online",
+		"Joined Mar 2020 · 10 games (+6 -3 =1)",
+		"blitz 2900 (8) · rapid 2700? (2)",
+		"Head to head: ½ - 2½ in 3 games",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("expected profile to contain %q, got\n%s", expected, text)
+		}
+	}
+	if strings.Contains(text, "bullet") {
+		t.Errorf("expected speeds without games to be hidden, got\n%s", text)
+	}
+}
+
+func TestFormatScore(t *testing.T) {
+	for score, expected := range map[float64]string{0: "0", 0.5: "½", 3: "3", 3.5: "3½"} {
+		if formatted := formatScore(score); formatted != expected {
+			t.Errorf("expected %v to be formatted as %s, got %s", score, expected, formatted)
+		}
+	}
+}
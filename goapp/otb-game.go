@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aherve/eChess/goapp/lichess"
+)
+
+// OTBGameSettings describe a game between two players sitting at the board
+type OTBGameSettings struct {
+	Time      int // minutes
+	Increment int // seconds
+	Mode      IncrementMode
+	// PGN headers, which can still be edited when the game is saved
+	White string
+	Black string
+	Event string
+	// Arbiter watches the board for illegal moves, touched pieces and moves out of turn
+	Arbiter bool
+}
+
+func (s OTBGameSettings) ClockSettings() ClockSettings {
+	return ClockSettings{
+		Initial:   time.Duration(s.Time) * time.Minute,
+		Increment: time.Duration(s.Increment) * time.Second,
+		Mode:      s.Mode,
+	}
+}
+
+// Rematch keeps the same settings: the players switch seats themselves
+func (s OTBGameSettings) Rematch(color string) LocalGameSettings {
+	return s
+}
+
+// Play follows the game on the board until it ends
+func (s OTBGameSettings) Play(state *MainState) {
+	game := NewOTBGame(s)
+	log.Printf("Starting over the board game %s, %d+%d with %s increment", game.ID(), s.Time, s.Increment, s.Mode)
+
+	state.SetBackend(game)
+	defer state.SetBackend(LichessBackend{})
+
+	if game.arbiter != nil {
+		state.UIState().SetLastIncident(nil)
+		go watchBoard(game.done, state, game.arbiter)
+	}
+
+	state.Game().UpdateFromFindGame(game.GameEvent())
+	handleGame(state)
+}
+
+// OTBGame is a game between two players at the board. Moves of both colors are read from the board, and the clock runs in the UI
+type OTBGame struct {
+	*localGame
+	settings OTBGameSettings
+
+	record   *GameRecord
+	recorded chan struct{} // closed when the record is complete
+	arbiter  *Arbiter      // nil unless the arbiter mode is on
+}
+
+// NewOTBGame creates the game. White sits at the bottom of the screen, where our side is shown in online games
+func NewOTBGame(settings OTBGameSettings) *OTBGame {
+	id := fmt.Sprintf("otb%d", time.Now().Unix())
+	var arbiter *Arbiter
+	if settings.Arbiter {
+		arbiter = NewArbiter()
+	}
+	return &OTBGame{
+		localGame: newLocalGame(lichess.GameEvent{
+			FullID:   id,
+			GameId:   id,
+			Color:    "white",
+			Opponent: lichess.Opponent{Username: orDefault(settings.Black, "Black")},
+			Speed:    lichess.SeekRequest{Time: settings.Time, Increment: settings.Increment}.Speed(),
+		}, settings.ClockSettings()),
+		settings: settings,
+		record:   NewGameRecord(id, settings, time.Now()),
+		recorded: make(chan struct{}),
+		arbiter:  arbiter,
+	}
+}
+
+func orDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+func (g *OTBGame) Settings() OTBGameSettings {
+	return g.settings
+}
+
+func (g *OTBGame) PlaysBothSides() bool {
+	return true
+}
+
+// StreamGame plays the game until it ends, or ctx is cancelled
+func (g *OTBGame) StreamGame(ctx context.Context, gameID string, chans *lichess.LichessEventChans) {
+	defer close(g.recorded)
+
+	g.record.StartedAt = time.Now()
+	if status, winner := g.run(ctx, chans, g.playTurn); status != "" {
+		g.record.SetResult(status, winner)
+	}
+}
+
+// Record returns the record of the game, once it is over, with the incidents noticed by the arbiter
+func (g *OTBGame) Record() *GameRecord {
+	<-g.recorded
+	if g.arbiter != nil {
+		g.record.Incidents = g.arbiter.Incidents()
+	}
+	return g.record
+}
+
+func (g *OTBGame) playTurn(ctx context.Context) (string, string) {
+	flag := g.flag()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return "", ""
+		case move := <-g.boardMoves:
+			turn := g.chessGame.Position().Turn()
+			spent, err := g.apply(move)
+			if err != nil {
+				log.Printf("Invalid move %s: %v", move, err)
+				continue
+			}
+			now := time.Now()
+			g.record.Moves = append(g.record.Moves, RecordedMove{
+				Move:     move,
+				PlayedAt: now,
+				Spent:    spent,
+				Clock:    g.clock.Remaining(turn, now),
+			})
+			return "", ""
+		case <-flag:
+			return "outoftime", opposite(g.turn())
+		case command := <-g.commands:
+			switch command {
+			case "resign":
+				// the buttons are shared: the player to move resigns
+				return "resign", opposite(g.turn())
+			case "abort":
+				if len(g.moves) < 2 {
+					return "aborted", ""
+				}
+				log.Println("Too late to abort the game")
+			case "draw":
+				// both players are at the board, so the draw is agreed
+				return "draw", ""
+			}
+		}
+	}
+}
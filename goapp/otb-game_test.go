@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aherve/eChess/goapp/lichess"
+)
+
+func startOTBGame(settings OTBGameSettings, initial time.Duration) (*OTBGame, *lichess.LichessEventChans, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	game := NewOTBGame(settings)
+	game.clockSettings.Initial = initial
+	chans := lichess.NewLichessEventChans()
+	go game.StreamGame(ctx, game.ID(), chans)
+	<-chans.GameFullChan
+	return game, chans, cancel
+}
+
+func TestOTBGame(t *testing.T) {
+	game, chans, cancel := startOTBGame(OTBGameSettings{Time: 5, Increment: 3, Mode: BronsteinIncrement}, 5*time.Minute)
+	defer cancel()
+	ctx := context.Background()
+
+	if !game.PlaysBothSides() || !game.Offline() {
+		t.Error("expected both sides to be played offline")
+	}
+
+	// moves of both colors come from the board
+	for _, expected := range []string{"e2e4", "e2e4 e7e5", "e2e4 e7e5 g1f3"} {
+		move := expected[len(expected)-4:]
+		if err := game.PlayMove(ctx, game.ID(), move); err != nil {
+			t.Fatalf("failed to play %s: %v", move, err)
+		}
+		if state := <-chans.GameStateChan; state.Moves != expected || state.Status != "started" {
+			t.Errorf("expected moves %q, got %+v", expected, state)
+		}
+	}
+
+	// illegal moves are flagged by the board, and never recorded
+	if err := game.PlayMove(ctx, game.ID(), "e8e6"); err != nil {
+		t.Fatalf("failed to send the move: %v", err)
+	}
+
+	// black is to move, and resigns
+	game.Resign(ctx, game.ID())
+	if state := <-chans.GameStateChan; state.Status != "resign" || state.Winner != "white" {
+		t.Errorf("expected black to resign, got %+v", state)
+	}
+	<-chans.GameEnded
+
+	record := game.Record()
+	if moves := strings.Join(record.UCIMoves(), " "); moves != "e2e4 e7e5 g1f3" {
+		t.Errorf("expected the moves to be recorded, got %s", moves)
+	}
+	if record.Headers.Result != "1-0" || record.Moves[0].Clock > 5*time.Minute {
+		t.Errorf("unexpected record %+v", record)
+	}
+
+	if err := game.PlayMove(ctx, game.ID(), "e8e7"); err == nil {
+		t.Error("expected moves to be rejected once the game is over")
+	}
+}
+
+func TestOTBGameMate(t *testing.T) {
+	game, chans, cancel := startOTBGame(OTBGameSettings{Time: 5}, 5*time.Minute)
+	defer cancel()
+
+	for _, move := range []string{"f2f3", "e7e5", "g2g4", "d8h4"} {
+		if err := game.PlayMove(context.Background(), game.ID(), move); err != nil {
+			t.Fatalf("failed to play %s: %v", move, err)
+		}
+		<-chans.GameStateChan
+	}
+	if state := <-chans.GameStateChan; state.Status != "mate" || state.Winner != "black" {
+		t.Errorf("expected black to mate, got %+v", state)
+	}
+	<-chans.GameEnded
+}
+
+func TestOTBGameFlag(t *testing.T) {
+	_, chans, cancel := startOTBGame(OTBGameSettings{Time: 1, Mode: FischerIncrement}, 50*time.Millisecond)
+	defer cancel()
+
+	if state := <-chans.GameStateChan; state.Status != "outoftime" || state.Winner != "black" || state.Wtime != 0 {
+		t.Errorf("expected white to flag, got %+v", state)
+	}
+	<-chans.GameEnded
+}
+
+func TestOTBGameDraw(t *testing.T) {
+	game, chans, cancel := startOTBGame(OTBGameSettings{Time: 5}, 5*time.Minute)
+	defer cancel()
+
+	game.Draw(context.Background(), game.ID(), true)
+	if state := <-chans.GameStateChan; state.Status != "draw" || state.Winner != "" {
+		t.Errorf("expected a draw, got %+v", state)
+	}
+	<-chans.GameEnded
+}
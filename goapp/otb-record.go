@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aherve/eChess/goapp/archive"
+	"github.com/aherve/eChess/goapp/lichess"
+	"github.com/notnil/chess"
+)
+
+// GameRecorder is implemented by backends that record their games themselves. Their games are saved from the UI, with headers filled by the players
+type GameRecorder interface {
+	Record() *GameRecord
+}
+
+// PGNHeaders are the tags of a recorded game, filled from the UI
+type PGNHeaders struct {
+	Event  string
+	Site   string
+	Date   string // YYYY.MM.DD
+	White  string
+	Black  string
+	Result string // "1-0", "0-1", "1/2-1/2" or "*"
+}
+
+// RecordedMove is a move played on the board, with the time it was played and the clock of the player after the move
+type RecordedMove struct {
+	Move     string // UCI
+	PlayedAt time.Time
+	Spent    time.Duration
+	Clock    time.Duration
+}
+
+// GameRecord is a game played over the board, move by move
+type GameRecord struct {
+	GameID      string
+	Headers     PGNHeaders
+	StartedAt   time.Time
+	Speed       lichess.GameSpeed
+	TimeControl string // such as 900+10
+	Termination string
+	Moves       []RecordedMove
+	Incidents   []Incident // noticed by the arbiter, if it watched the game
+}
+
+func NewGameRecord(gameID string, settings OTBGameSettings, startedAt time.Time) *GameRecord {
+	return &GameRecord{
+		GameID: gameID,
+		Headers: PGNHeaders{
+			Event:  settings.Event,
+			Site:   "eChess",
+			Date:   startedAt.Format("2006.01.02"),
+			White:  settings.White,
+			Black:  settings.Black,
+			Result: "*",
+		},
+		StartedAt:   startedAt,
+		Speed:       lichess.SeekRequest{Time: settings.Time, Increment: settings.Increment}.Speed(),
+		TimeControl: fmt.Sprintf("%d+%d", settings.Time*60, settings.Increment),
+	}
+}
+
+// SetResult records how the game ended, from its lichess status and winner
+func (r *GameRecord) SetResult(status, winner string) {
+	r.Termination = describeStatus(status, winner)
+	switch {
+	case status == "aborted":
+		r.Headers.Result = "*"
+	case winner == "white":
+		r.Headers.Result = "1-0"
+	case winner == "black":
+		r.Headers.Result = "0-1"
+	default:
+		r.Headers.Result = "1/2-1/2"
+	}
+}
+
+// UCIMoves returns the moves of the game
+func (r *GameRecord) UCIMoves() []string {
+	moves := []string{}
+	for _, move := range r.Moves {
+		moves = append(moves, move.Move)
+	}
+	return moves
+}
+
+// PGN writes the game with its headers. Each move is annotated with the clock of the player and the time spent on the move, and the arbiter incidents of the turn
+func (r *GameRecord) PGN() string {
+	tags := [][2]string{
+		{"Event", orDefault(r.Headers.Event, "?")},
+		{"Site", orDefault(r.Headers.Site, "?")},
+		{"Date", orDefault(r.Headers.Date, "?")},
+		{"White", orDefault(r.Headers.White, "?")},
+		{"Black", orDefault(r.Headers.Black, "?")},
+		{"Result", r.Headers.Result},
+		{"UTCTime", r.StartedAt.UTC().Format("15:04:05")},
+		{"TimeControl", r.TimeControl},
+	}
+	if r.Termination != "" {
+		tags = append(tags, [2]string{"Termination", r.Termination})
+	}
+
+	var sb strings.Builder
+	for _, tag := range tags {
+		fmt.Fprintf(&sb, "[%s %q]\n", tag[0], tag[1])
+	}
+	sb.WriteString("\n")
+
+	// incidents are commented after the move that ended their turn, or the last move. Without moves, they follow the result, as the parser doesn't take comments before the first move
+	incidents := map[int][]string{}
+	for _, incident := range r.Incidents {
+		i := min(incident.Ply, len(r.Moves)-1)
+		incidents[i] = append(incidents[i], "Arbiter: "+incident.String())
+	}
+
+	words := []string{}
+	g := chess.NewGame(chess.UseNotation(chess.UCINotation{}))
+	for i, move := range r.Moves {
+		position := g.Position()
+		if err := g.MoveStr(move.Move); err != nil {
+			// moves are validated when they are played, this is only a safeguard
+			words = append(words, fmt.Sprintf("{ Invalid move %s }", move.Move))
+			break
+		}
+		if i%2 == 0 {
+			words = append(words, fmt.Sprintf("%d.", i/2+1))
+		}
+		comment := fmt.Sprintf("[%%clk %s] [%%emt %s]", formatPGNClock(move.Clock), formatPGNClock(move.Spent))
+		if len(incidents[i]) > 0 {
+			comment += " " + strings.Join(incidents[i], "; ")
+		}
+		words = append(words, chess.AlgebraicNotation{}.Encode(position, g.Moves()[i]), "{ "+comment+" }")
+	}
+	words = append(words, r.Headers.Result)
+	if len(incidents[-1]) > 0 {
+		words = append(words, "{ "+strings.Join(incidents[-1], "; ")+" }")
+	}
+	sb.WriteString(strings.Join(words, " ") + "\n")
+	return sb.String()
+}
+
+// formatPGNClock formats a duration as h:mm:ss
+func formatPGNClock(d time.Duration) string {
+	seconds := int(d.Round(time.Second).Seconds())
+	return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
+
+// saveRecord stores the game in the archive, and exports its PGN to exportPath unless it is empty
+func saveRecord(gameArchive *archive.Archive, record *GameRecord, exportPath string) error {
+	pgn := record.PGN()
+	if exportPath != "" {
+		if err := os.WriteFile(exportPath, []byte(pgn), 0644); err != nil {
+			return fmt.Errorf("error exporting the game to %s: %w", exportPath, err)
+		}
+	}
+	if gameArchive == nil {
+		return nil
+	}
+
+	// the outcome is white's, as white sits on our side of the board. An unfinished game (*) is not a draw
+	outcome := "aborted"
+	switch record.Headers.Result {
+	case "1-0":
+		outcome = "won"
+	case "0-1":
+		outcome = "lost"
+	case "1/2-1/2":
+		outcome = "drawn"
+	}
+	entry := archive.Entry{
+		GameID:   record.GameID,
+		Date:     record.StartedAt,
+		Opponent: fmt.Sprintf("%s - %s", orDefault(record.Headers.White, "?"), orDefault(record.Headers.Black, "?")),
+		Color:    "white",
+		Speed:    string(record.Speed),
+		Outcome:  outcome,
+		Result:   record.Headers.Result,
+		Reason:   record.Termination,
+	}
+	return gameArchive.Add(entry, pgn)
+}
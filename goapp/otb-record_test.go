@@ -0,0 +1,147 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aherve/eChess/goapp/archive"
+	"github.com/notnil/chess"
+)
+
+func newTestRecord() *GameRecord {
+	start := time.Date(2026, 3, 14, 18, 30, 0, 0, time.UTC)
+	record := NewGameRecord("otb1", OTBGameSettings{Time: 15, Increment: 10, White: "Alice", Black: "Bob", Event: "Club night"}, start)
+	for i, move := range []string{"f2f3", "e7e5", "g2g4", "d8h4"} {
+		record.Moves = append(record.Moves, RecordedMove{
+			Move:     move,
+			PlayedAt: start.Add(time.Duration(i+1) * 3 * time.Second),
+			Spent:    3 * time.Second,
+			Clock:    15*time.Minute + 7*time.Second,
+		})
+	}
+	record.SetResult("mate", "black")
+	return record
+}
+
+func TestGameRecordPGN(t *testing.T) {
+	record := newTestRecord()
+	if record.Headers.Result != "0-1" || record.Termination != "Checkmate" {
+		t.Errorf("unexpected result %s (%s)", record.Headers.Result, record.Termination)
+	}
+
+	pgn := record.PGN()
+	for _, expected := range []string{
+		`[Event "Club night"]`,
+		`[Site "eChess"]`,
+		`[Date "2026.03.14"]`,
+		`[White "Alice"]`,
+		`[Black "Bob"]`,
+		`[Result "0-1"]`,
+		`[UTCTime "18:30:00"]`,
+		`[TimeControl "900+10"]`,
+		"1. f3 { [%clk 0:15:07] [%emt 0:00:03] } e5",
+		"2. g4 { [%clk 0:15:07] [%emt 0:00:03] } Qh4# { [%clk 0:15:07] [%emt 0:00:03] } 0-1",
+	} {
+		if !strings.Contains(pgn, expected) {
+			t.Errorf("expected PGN to contain %q, got\n%s", expected, pgn)
+		}
+	}
+
+	// the recorded game can be replayed
+	replay, err := NewReplay(archive.Entry{}, pgn)
+	if err != nil {
+		t.Fatalf("failed to replay the PGN: %v", err)
+	}
+	if replay.Len() != 4 || !replay.Seek(1) || replay.Comment() != "⏱ 0:15:07" {
+		t.Errorf("unexpected replay of %d moves, comment %q", replay.Len(), replay.Comment())
+	}
+
+	record.SetResult("aborted", "")
+	if record.Headers.Result != "*" {
+		t.Errorf("expected aborted games to have no result, got %s", record.Headers.Result)
+	}
+}
+
+func TestGameRecordIncidents(t *testing.T) {
+	record := newTestRecord()
+	record.Incidents = []Incident{
+		{Ply: 1, Kind: TouchMoveIncident, Color: chess.Black, Squares: []chess.Square{chess.G8}},
+		{Ply: 4, Kind: OutOfTurnIncident, Color: chess.Black, Squares: []chess.Square{chess.A6}},
+	}
+
+	pgn := record.PGN()
+	for _, expected := range []string{
+		"e5 { [%clk 0:15:07] [%emt 0:00:03] Arbiter: 1... Black touched the piece on g8 without moving it }",
+		"Qh4# { [%clk 0:15:07] [%emt 0:00:03] Arbiter: 3. Black moved out of turn to a6 } 0-1",
+	} {
+		if !strings.Contains(pgn, expected) {
+			t.Errorf("expected PGN to contain %q, got\n%s", expected, pgn)
+		}
+	}
+
+	replay, err := NewReplay(archive.Entry{}, pgn)
+	if err != nil {
+		t.Fatalf("failed to replay the PGN: %v", err)
+	}
+	if !replay.Seek(2) || !strings.Contains(replay.Comment(), "touched the piece on g8") {
+		t.Errorf("expected the incident in the replay, got %q", replay.Comment())
+	}
+}
+
+func TestGameRecordIncidentsWithoutMoves(t *testing.T) {
+	record := NewGameRecord("otb2", OTBGameSettings{Time: 15, White: "Alice", Black: "Bob"}, time.Now())
+	record.Incidents = []Incident{{Kind: OutOfTurnIncident, Color: chess.Black, Squares: []chess.Square{chess.E5}}}
+	record.SetResult("resign", "black")
+
+	pgn := record.PGN()
+	if !strings.Contains(pgn, "0-1 { Arbiter: 1. Black moved out of turn to e5 }") {
+		t.Errorf("expected the incident after the result, got\n%s", pgn)
+	}
+	if _, err := NewReplay(archive.Entry{}, pgn); err != nil {
+		t.Errorf("failed to replay the PGN: %v", err)
+	}
+}
+
+func TestSaveRecord(t *testing.T) {
+	dir := t.TempDir()
+	gameArchive, err := archive.Open(filepath.Join(dir, "games"))
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+
+	record := newTestRecord()
+	exportPath := filepath.Join(dir, "export.pgn")
+	if err := saveRecord(gameArchive, record, exportPath); err != nil {
+		t.Fatalf("failed to save record: %v", err)
+	}
+
+	exported, err := os.ReadFile(exportPath)
+	if err != nil || string(exported) != record.PGN() {
+		t.Errorf("expected the PGN to be exported, got %v", err)
+	}
+
+	entries := gameArchive.Search(archive.Query{Opponent: "bob"})
+	if len(entries) != 1 {
+		t.Fatalf("expected the game to be archived, got %+v", entries)
+	}
+	if entries[0].Opponent != "Alice - Bob" || entries[0].Outcome != "lost" || entries[0].Result != "0-1" || entries[0].Speed != "rapid" {
+		t.Errorf("unexpected entry %+v", entries[0])
+	}
+
+	unfinished := newTestRecord()
+	unfinished.GameID = "unfinished"
+	unfinished.Headers.Result = "*"
+	if err := saveRecord(gameArchive, unfinished, ""); err != nil {
+		t.Fatalf("failed to save record: %v", err)
+	}
+	if entries := gameArchive.Search(archive.Query{Outcome: "aborted"}); len(entries) != 1 || entries[0].GameID != "unfinished" {
+		t.Errorf("expected the unfinished game to be archived as aborted, got %+v", entries)
+	}
+
+	if err := saveRecord(gameArchive, record, filepath.Join(dir, "missing", "export.pgn")); err == nil {
+		t.Error("expected an error exporting to a missing directory")
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/aherve/eChess/goapp/archive"
+	"github.com/notnil/chess"
+)
+
+// Replay walks through an archived game. The board is guided with the LEDs to the position after the current move
+type Replay struct {
+	entry   archive.Entry
+	start   *chess.Position
+	history []*chess.MoveHistory
+	index   int // number of moves played on the board
+
+	mu sync.RWMutex
+}
+
+// NewReplay parses the PGN of an archived game
+func NewReplay(entry archive.Entry, pgn string) (replay *Replay, err error) {
+	// notnil/chess panics on some malformed PGN, e.g. with a comment before the first move
+	defer func() {
+		if r := recover(); r != nil {
+			replay, err = nil, fmt.Errorf("invalid PGN: %v", r)
+		}
+	}()
+
+	option, err := chess.PGN(strings.NewReader(pgn))
+	if err != nil {
+		return nil, err
+	}
+	g := chess.NewGame(option)
+
+	return &Replay{
+		entry:   entry,
+		start:   g.Positions()[0],
+		history: g.MoveHistory(),
+	}, nil
+}
+
+func (r *Replay) Entry() archive.Entry {
+	return r.entry
+}
+
+func (r *Replay) Index() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.index
+}
+
+func (r *Replay) Len() int {
+	return len(r.history)
+}
+
+// Position returns the position after the current move
+func (r *Replay) Position() *chess.Position {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.index == 0 {
+		return r.start
+	}
+	return r.history[r.index-1].PostPosition
+}
+
+// Next moves one move forward, and returns false at the end of the game
+func (r *Replay) Next() bool {
+	return r.Seek(r.Index() + 1)
+}
+
+// Prev moves one move back, and returns false at the start of the game
+func (r *Replay) Prev() bool {
+	return r.Seek(r.Index() - 1)
+}
+
+// Seek goes to the position after the given number of moves, and returns false if it is out of the game
+func (r *Replay) Seek(index int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if index < 0 || index > len(r.history) {
+		return false
+	}
+	r.index = index
+	return true
+}
+
+// MoveText returns the current move in standard algebraic notation, such as "12. Nf3" or "12... Nf6"
+func (r *Replay) MoveText() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.index == 0 {
+		return "Initial position"
+	}
+	h := r.history[r.index-1]
+	san := chess.AlgebraicNotation{}.Encode(h.PrePosition, h.Move)
+
+	// the full move number is the last field of the FEN, and games from a position don't start at 1
+	fields := strings.Fields(h.PrePosition.String())
+	number := fields[len(fields)-1]
+	if h.PrePosition.Turn() == chess.White {
+		return fmt.Sprintf("%s. %s", number, san)
+	}
+	return fmt.Sprintf("%s... %s", number, san)
+}
+
+// commands are annotations such as [%clk 0:14:52] or [%eval 0.3]
+var commentCommand = regexp.MustCompile(`\[%(\w+) ([^\]]*)\]`)
+
+// Comment returns the comments of the current move, with the clock annotation displayed and the other annotations removed
+func (r *Replay) Comment() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.index == 0 {
+		return ""
+	}
+
+	parts := []string{}
+	for _, comment := range r.history[r.index-1].Comments {
+		for _, command := range commentCommand.FindAllStringSubmatch(comment, -1) {
+			if command[1] == "clk" {
+				parts = append(parts, "⏱ "+command[2])
+			}
+		}
+		if text := strings.TrimSpace(commentCommand.ReplaceAllString(comment, "")); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, " · ")
+}
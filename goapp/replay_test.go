@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aherve/eChess/goapp/archive"
+	"github.com/notnil/chess"
+)
+
+const replayPGN = `[Event "Rated rapid game"]
+[White "someone"]
+[Black "opponent"]
+[Result "0-1"]
+
+1. f3 { [%clk 0:15:00] } 1... e5 { [%clk 0:14:58] Solid } 2. g4 { [%clk 0:14:50] } 2... Qh4# { [%clk 0:14:40] } 0-1
+`
+
+func TestReplay(t *testing.T) {
+	replay, err := NewReplay(archive.Entry{GameID: "abcdefgh"}, replayPGN)
+	if err != nil {
+		t.Fatalf("failed to parse PGN: %v", err)
+	}
+	if replay.Len() != 4 {
+		t.Fatalf("expected 4 moves, got %d", replay.Len())
+	}
+
+	if replay.MoveText() != "Initial position" || replay.Prev() {
+		t.Errorf("expected the replay to start from the initial position")
+	}
+	if replay.Position().Board().Piece(chess.F2) != chess.WhitePawn {
+		t.Errorf("expected the initial position to be the standard one")
+	}
+
+	replay.Next()
+	replay.Next()
+	if text := replay.MoveText(); text != "1... e5" {
+		t.Errorf("expected 1... e5, got %s", text)
+	}
+	if comment := replay.Comment(); comment != "⏱ 0:14:58 · Solid" {
+		t.Errorf("expected the clock and the comment, got %q", comment)
+	}
+	if replay.Position().Board().Piece(chess.E5) != chess.BlackPawn {
+		t.Errorf("expected a black pawn on e5")
+	}
+
+	replay.Seek(replay.Len())
+	if text := replay.MoveText(); text != "2... Qh4#" {
+		t.Errorf("expected 2... Qh4#, got %s", text)
+	}
+	if replay.Next() {
+		t.Errorf("expected the replay to stop at the last move")
+	}
+
+	replay.Prev()
+	if text := replay.MoveText(); text != "2. g4" {
+		t.Errorf("expected 2. g4, got %s", text)
+	}
+}
+
+func TestReplayInvalidPGN(t *testing.T) {
+	if _, err := NewReplay(archive.Entry{}, "{ UCI moves: e2e4 } *"); err == nil {
+		t.Errorf("expected a PGN without moves before the comment to fail")
+	}
+	if _, err := NewReplay(archive.Entry{}, "1. e4 e4 *"); err == nil {
+		t.Errorf("expected an invalid move to fail")
+	}
+}
@@ -0,0 +1,264 @@
+package uci
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrEngineStopped is returned when the engine process exits while we wait for an answer
+var ErrEngineStopped = errors.New("engine stopped")
+
+// ErrNoMove is returned when the position has no legal move
+var ErrNoMove = errors.New("no legal move")
+
+// StartTimeout bounds the handshake with the engine
+const StartTimeout = 10 * time.Second
+
+// Engine talks UCI to a chess engine running as a subprocess
+type Engine struct {
+	Name string // as announced by the engine
+
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	lines chan string // lines written by the engine, closed when it exits
+	// closed by Close, when the engine output is not read anymore
+	closing chan struct{}
+	// closed once the engine output is fully read
+	readDone chan struct{}
+
+	mu sync.Mutex
+}
+
+// Position is the position to search, as an initial FEN and the UCI moves played since then
+type Position struct {
+	Fen   string // empty for the standard starting position
+	Moves []string
+}
+
+// SearchLimits bounds the search. Clock times are in milliseconds, and a zero MoveTime means the engine manages its clock
+type SearchLimits struct {
+	WTime    int
+	BTime    int
+	WInc     int
+	BInc     int
+	MoveTime int
+}
+
+// Start runs the engine and completes the UCI handshake
+func Start(ctx context.Context, path string, args ...string) (*Engine, error) {
+	cmd := exec.Command(path, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error starting engine %s: %w", path, err)
+	}
+
+	e := &Engine{
+		cmd:      cmd,
+		stdin:    stdin,
+		lines:    make(chan string, 64),
+		closing:  make(chan struct{}),
+		readDone: make(chan struct{}),
+	}
+	go e.read(stdout)
+
+	ctx, cancel := context.WithTimeout(ctx, StartTimeout)
+	defer cancel()
+
+	if err := e.send("uci"); err != nil {
+		e.Close()
+		return nil, err
+	}
+	for {
+		line, err := e.readLine(ctx)
+		if err != nil {
+			e.Close()
+			return nil, fmt.Errorf("error waiting for uciok: %w", err)
+		}
+		if name, ok := strings.CutPrefix(line, "id name "); ok {
+			e.Name = name
+		}
+		if line == "uciok" {
+			break
+		}
+	}
+	if err := e.waitReady(ctx); err != nil {
+		e.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *Engine) read(stdout io.Reader) {
+	defer close(e.readDone)
+	defer close(e.lines)
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		select {
+		case e.lines <- strings.TrimSpace(scanner.Text()):
+		case <-e.closing:
+			// nobody reads the lines anymore: they are dropped until the engine exits
+		}
+	}
+}
+
+func (e *Engine) readLine(ctx context.Context) (string, error) {
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case line, ok := <-e.lines:
+		if !ok {
+			return "", ErrEngineStopped
+		}
+		return line, nil
+	}
+}
+
+func (e *Engine) send(command string) error {
+	_, err := fmt.Fprintln(e.stdin, command)
+	if err != nil {
+		return fmt.Errorf("error sending %q to the engine: %w", command, err)
+	}
+	return nil
+}
+
+func (e *Engine) waitReady(ctx context.Context) error {
+	if err := e.send("isready"); err != nil {
+		return err
+	}
+	for {
+		line, err := e.readLine(ctx)
+		if err != nil {
+			return fmt.Errorf("error waiting for readyok: %w", err)
+		}
+		if line == "readyok" {
+			return nil
+		}
+	}
+}
+
+// SetOption sets an engine option, such as "Skill Level"
+func (e *Engine) SetOption(ctx context.Context, name, value string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.send(fmt.Sprintf("setoption name %s value %s", name, value)); err != nil {
+		return err
+	}
+	return e.waitReady(ctx)
+}
+
+// NewGame tells the engine the next searches belong to a new game
+func (e *Engine) NewGame(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.send("ucinewgame"); err != nil {
+		return err
+	}
+	return e.waitReady(ctx)
+}
+
+// command builds the position and go commands of a search
+func (p Position) command() string {
+	command := "position startpos"
+	if p.Fen != "" {
+		command = "position fen " + p.Fen
+	}
+	if len(p.Moves) > 0 {
+		command += " moves " + strings.Join(p.Moves, " ")
+	}
+	return command
+}
+
+func (l SearchLimits) command() string {
+	if l.MoveTime > 0 {
+		return fmt.Sprintf("go movetime %d", l.MoveTime)
+	}
+	return fmt.Sprintf("go wtime %d btime %d winc %d binc %d", l.WTime, l.BTime, l.WInc, l.BInc)
+}
+
+// BestMove searches the position, and returns the best move in UCI notation. Cancelling ctx stops the search
+func (e *Engine) BestMove(ctx context.Context, position Position, limits SearchLimits) (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.send(position.command()); err != nil {
+		return "", err
+	}
+	if err := e.send(limits.command()); err != nil {
+		return "", err
+	}
+
+	readCtx := ctx
+	stopped := false
+	for {
+		line, err := e.readLine(readCtx)
+		if err != nil && !stopped && ctx.Err() != nil {
+			// the engine still answers with a bestmove, which must be consumed before the next search
+			if err := e.send("stop"); err != nil {
+				return "", err
+			}
+			stopped = true
+			var cancel context.CancelFunc
+			readCtx, cancel = context.WithTimeout(context.Background(), StartTimeout)
+			defer cancel()
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if move, ok := strings.CutPrefix(line, "bestmove "); ok {
+			if stopped {
+				return "", ctx.Err()
+			}
+			move = strings.Fields(move)[0]
+			if move == "(none)" {
+				return "", ErrNoMove
+			}
+			return move, nil
+		}
+	}
+}
+
+// Close asks the engine to quit, and kills it if it doesn't
+func (e *Engine) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.send("quit"); err != nil {
+		log.Printf("Error asking the engine to quit: %v", err)
+	}
+	e.stdin.Close()
+	close(e.closing)
+
+	done := make(chan error, 1)
+	go func() {
+		// Wait closes the output pipe, which must be read to the end first
+		<-e.readDone
+		done <- e.cmd.Wait()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(time.Second):
+		e.cmd.Process.Kill()
+		return <-done
+	}
+}
@@ -0,0 +1,112 @@
+package uci
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeEngine answers the UCI handshake, and always plays e7e5. Received commands are appended to a log file
+const fakeEngine = `#!/bin/sh
+while read line; do
+  echo "$line" >> "$0.log"
+  case "$line" in
+    uci) echo "id name Fake Engine"; echo "option name Skill Level type spin default 20 min 0 max 20"; echo "uciok";;
+    isready) echo "readyok";;
+    go*) echo "info depth 1 score cp 20"; echo "bestmove e7e5 ponder g1f3";;
+    quit) exit 0;;
+  esac
+done
+`
+
+func startFakeEngine(t *testing.T) (*Engine, string) {
+	return startEngineScript(t, fakeEngine)
+}
+
+func startEngineScript(t *testing.T, script string) (*Engine, string) {
+	path := filepath.Join(t.TempDir(), "engine.sh")
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write fake engine: %v", err)
+	}
+	engine, err := Start(context.Background(), path)
+	if err != nil {
+		t.Fatalf("failed to start fake engine: %v", err)
+	}
+	return engine, path + ".log"
+}
+
+func TestEngine(t *testing.T) {
+	engine, logPath := startFakeEngine(t)
+	ctx := context.Background()
+
+	if engine.Name != "Fake Engine" {
+		t.Errorf("expected the engine name to be read, got %q", engine.Name)
+	}
+	if err := engine.SetOption(ctx, "Skill Level", "3"); err != nil {
+		t.Fatalf("failed to set option: %v", err)
+	}
+	if err := engine.NewGame(ctx); err != nil {
+		t.Fatalf("failed to start a new game: %v", err)
+	}
+
+	move, err := engine.BestMove(ctx, Position{Moves: []string{"e2e4"}}, SearchLimits{WTime: 60000, BTime: 60000, WInc: 1000, BInc: 1000})
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if move != "e7e5" {
+		t.Errorf("expected e7e5, got %s", move)
+	}
+	if err := engine.Close(); err != nil {
+		t.Errorf("expected the engine to quit cleanly, got %v", err)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("failed to read the commands log: %v", err)
+	}
+	expected := "uci\nisready\nsetoption name Skill Level value 3\nisready\nucinewgame\nisready\nposition startpos moves e2e4\ngo wtime 60000 btime 60000 winc 1000 binc 1000\nquit\n"
+	if string(data) != expected {
+		t.Errorf("expected commands\n%s\ngot\n%s", expected, data)
+	}
+}
+
+func TestCloseWithUnreadOutput(t *testing.T) {
+	// the engine prints more lines than are buffered before quitting
+	chatty := strings.Replace(fakeEngine, "quit) exit 0;;", "quit) for i in $(seq 200); do echo \"info string bye $i\"; done; exit 0;;", 1)
+	engine, _ := startEngineScript(t, chatty)
+
+	start := time.Now()
+	if err := engine.Close(); err != nil {
+		t.Errorf("expected the engine to quit cleanly, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected the engine to quit without being killed, took %s", elapsed)
+	}
+	select {
+	case <-engine.readDone:
+	default:
+		t.Error("expected the engine output to be read to the end")
+	}
+}
+
+func TestSearchCommands(t *testing.T) {
+	position := Position{Fen: "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1", Moves: []string{"e2e4"}}
+	if command := position.command(); command != "position fen 4k3/8/8/8/8/8/4P3/4K3 w - - 0 1 moves e2e4" {
+		t.Errorf("unexpected position command %s", command)
+	}
+	if command := (Position{}).command(); command != "position startpos" {
+		t.Errorf("unexpected position command %s", command)
+	}
+	if command := (SearchLimits{MoveTime: 500, WTime: 1000}).command(); command != "go movetime 500" {
+		t.Errorf("unexpected go command %s", command)
+	}
+}
+
+func TestStartMissingEngine(t *testing.T) {
+	if _, err := Start(context.Background(), filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("expected a missing engine to fail")
+	}
+}
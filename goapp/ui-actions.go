@@ -9,29 +9,24 @@ import (
 type UIOutput int8
 
 const (
-	Seek1510 UIOutput = iota
-	Seek1530
-	Seek3020
-	Seek3030
-	Seek105
-	CancelSeek
+	CancelSeek UIOutput = iota
 	Resign
 	Abort
 	Draw
+	AcceptChallenge
+	DeclineChallenge
+	ProposeTakeback
+	AcceptTakeback
+	DeclineTakeback
+	AcceptDraw
+	DeclineDraw
+	Rematch
+	NewOpponent
+	ToggleCorrespondence
 )
 
 func (o UIOutput) String() string {
 	switch o {
-	case Seek105:
-		return "Seek10|5"
-	case Seek1510:
-		return "Seek15|10"
-	case Seek1530:
-		return "Seek15|30"
-	case Seek3020:
-		return "Seek30|20"
-	case Seek3030:
-		return "Seek30|30"
 	case CancelSeek:
 		return "CancelSeek"
 	case Resign:
@@ -40,6 +35,26 @@ func (o UIOutput) String() string {
 		return "Abort"
 	case Draw:
 		return "Draw"
+	case AcceptChallenge:
+		return "AcceptChallenge"
+	case DeclineChallenge:
+		return "DeclineChallenge"
+	case ProposeTakeback:
+		return "ProposeTakeback"
+	case AcceptTakeback:
+		return "AcceptTakeback"
+	case DeclineTakeback:
+		return "DeclineTakeback"
+	case AcceptDraw:
+		return "AcceptDraw"
+	case DeclineDraw:
+		return "DeclineDraw"
+	case Rematch:
+		return "Rematch"
+	case NewOpponent:
+		return "NewOpponent"
+	case ToggleCorrespondence:
+		return "ToggleCorrespondence"
 	default:
 		return "Unknown UIOutput"
 	}
@@ -71,36 +86,108 @@ func (p Promotion) String() string {
 }
 
 func emitActions(state *MainState) {
-	for output := range state.UIState().Output {
-		switch output {
+	ctx := state.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case output := <-state.UIState().Output:
+			emitAction(state, output)
+		case challenge := <-state.UIState().FriendChallenge:
+			state.UIState().ChallengeFriend(ctx, challenge)
+		case req := <-state.UIState().Seek:
+			state.UIState().CreateSeek(ctx, state.Backend(), req)
+		case challenge := <-state.UIState().AIChallenge:
+			// the new game is picked up by the backend
+			if _, err := lichess.ChallengeAI(ctx, challenge.Level, challenge.Request); err != nil {
+				log.Println(err)
+				state.UIState().Input <- ChallengeFailed
+			}
+		}
+	}
+}
+
+func emitAction(state *MainState, output UIOutput) {
+	ctx := state.Context()
+	backend := state.Backend()
+	switch output {
 
-		case Seek105:
-			state.UIState().CreateSeek("10", "5")
-		case Seek1510:
-			state.UIState().CreateSeek("15", "10")
-		case Seek1530:
-			state.UIState().CreateSeek("15", "30")
-		case Seek3020:
-			state.UIState().CreateSeek("30", "20")
-		case Seek3030:
-			state.UIState().CreateSeek("30", "30")
-		case CancelSeek:
-			state.UIState().CancelSeek()
-			state.UIState().Input <- StopSeeking
-		case Resign:
-			if gameID := state.Game().FullID(); gameID != "" {
-				lichess.ResignGame(gameID)
+	case CancelSeek:
+		state.UIState().CancelSeek()
+		state.UIState().Input <- StopSeeking
+	case Resign:
+		if gameID := state.Game().FullID(); gameID != "" {
+			backend.Resign(ctx, gameID)
+		}
+	case Abort:
+		if gameId := state.Game().FullID(); gameId != "" {
+			backend.Abort(ctx, gameId)
+		}
+	case Draw, AcceptDraw:
+		// offers, accepts or claims the draw
+		if gameId := state.Game().FullID(); gameId != "" {
+			backend.Draw(ctx, gameId, true)
+		}
+	case DeclineDraw:
+		if gameId := state.Game().FullID(); gameId != "" {
+			backend.Draw(ctx, gameId, false)
+		}
+	case AcceptChallenge:
+		if challenge := state.UIState().PendingChallenge(); challenge != nil {
+			state.UIState().ClearChallenge(challenge.ID)
+			if err := lichess.AcceptChallenge(ctx, challenge.ID); err != nil {
+				log.Println(err)
 			}
-		case Abort:
-			if gameId := state.Game().FullID(); gameId != "" {
-				lichess.AbortGame(gameId)
+		}
+	case DeclineChallenge:
+		if challenge := state.UIState().PendingChallenge(); challenge != nil {
+			state.UIState().ClearChallenge(challenge.ID)
+			if err := lichess.DeclineChallenge(ctx, challenge.ID, "generic"); err != nil {
+				log.Println(err)
 			}
-		case Draw:
-			if gameId := state.Game().FullID(); gameId != "" {
-				lichess.DrawGame(gameId)
+		}
+	case ProposeTakeback, AcceptTakeback:
+		if gameId := state.Game().FullID(); gameId != "" {
+			backend.Takeback(ctx, gameId, true)
+		}
+	case DeclineTakeback:
+		if gameId := state.Game().FullID(); gameId != "" {
+			backend.Takeback(ctx, gameId, false)
+		}
+	case Rematch:
+		result := state.UIState().LastResult()
+		if result == nil {
+			return
+		}
+		if settings := state.UIState().LastLocalGame(); result.Offline && settings != nil {
+			state.UIState().StartLocalGame(settings.Rematch(result.Color))
+			return
+		}
+		if result.Opponent.AI > 0 {
+			req := result.RematchRequest()
+			req.RematchOf = ""
+			if _, err := lichess.ChallengeAI(ctx, result.Opponent.AI, req); err != nil {
+				log.Println(err)
+				state.UIState().Input <- ChallengeFailed
 			}
-		default:
-			log.Println("Unknown UI Output:", output)
+			return
 		}
+		state.UIState().ChallengeFriend(ctx, FriendChallenge{
+			Username: result.Opponent.Username,
+			Request:  result.RematchRequest(),
+		})
+	case NewOpponent:
+		// same time control as our last seek, or as the last game if it didn't come from a seek
+		if req := state.UIState().LastSeek(); req != nil {
+			state.UIState().CreateSeek(ctx, backend, *req)
+		} else if result := state.UIState().LastResult(); result != nil {
+			state.UIState().CreateSeek(ctx, backend, result.SeekRequest())
+		}
+	case ToggleCorrespondence:
+		enabled := !state.UIState().CorrespondenceMode()
+		log.Printf("Correspondence mode enabled: %v", enabled)
+		state.UIState().SetCorrespondenceMode(enabled)
+	default:
+		log.Println("Unknown UI Output:", output)
 	}
 }
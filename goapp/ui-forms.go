@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+
+	"github.com/aherve/eChess/goapp/lichess"
+	"github.com/rivo/tview"
+)
+
+var colorOptions = []string{"random", "white", "black"}
+
+func friendChallengeForm(state *MainState, onSubmit func(username string), onClose func()) *tview.Form {
+	form := tview.NewForm().
+		AddInputField("Username", "", 20, nil, nil).
+		AddInputField("Minutes", "15", 5, tview.InputFieldInteger, nil).
+		AddInputField("Increment", "10", 5, tview.InputFieldInteger, nil).
+		AddDropDown("Color", colorOptions, 0, nil).
+		AddCheckbox("Rated", false, nil)
+
+	form.AddButton("Challenge", func() {
+		username := formText(form, "Username")
+		if username == "" {
+			form.SetTitle("Username is required")
+			return
+		}
+		_, color := form.GetFormItemByLabel("Color").(*tview.DropDown).GetCurrentOption()
+
+		challenge := FriendChallenge{
+			Username: username,
+			Request: lichess.ChallengeRequest{
+				Rated:          form.GetFormItemByLabel("Rated").(*tview.Checkbox).IsChecked(),
+				ClockLimit:     formInt(form, "Minutes") * 60,
+				ClockIncrement: formInt(form, "Increment"),
+				Color:          color,
+			},
+		}
+		onSubmit(username)
+		onClose()
+		state.UIState().FriendChallenge <- challenge
+	})
+	form.AddButton("Back", onClose)
+
+	form.SetBorder(true).SetTitle("Challenge a friend")
+	return form
+}
+
+var seekVariants = []string{"standard", "chess960"}
+
+var ratingRangeOptions = []string{"any", "absolute", "relative"}
+
+func customSeekForm(state *MainState, onClose func()) *tview.Form {
+	form := tview.NewForm().
+		AddInputField("Minutes", "15", 5, tview.InputFieldInteger, nil).
+		AddInputField("Increment", "10", 5, tview.InputFieldInteger, nil).
+		AddInputField("Days (correspondence)", "", 5, tview.InputFieldInteger, nil).
+		AddCheckbox("Rated", true, nil).
+		AddDropDown("Color", colorOptions, 0, nil).
+		AddDropDown("Variant", seekVariants, 0, nil).
+		AddDropDown("Rating range", ratingRangeOptions, 0, nil).
+		AddInputField("Min rating", "", 6, tview.InputFieldInteger, nil).
+		AddInputField("Max rating", "", 6, tview.InputFieldInteger, nil)
+
+	form.AddButton("Seek", func() {
+		_, color := form.GetFormItemByLabel("Color").(*tview.DropDown).GetCurrentOption()
+		_, variant := form.GetFormItemByLabel("Variant").(*tview.DropDown).GetCurrentOption()
+		_, rangeMode := form.GetFormItemByLabel("Rating range").(*tview.DropDown).GetCurrentOption()
+
+		req := lichess.SeekRequest{
+			Rated:     form.GetFormItemByLabel("Rated").(*tview.Checkbox).IsChecked(),
+			Time:      formInt(form, "Minutes"),
+			Increment: formInt(form, "Increment"),
+			Days:      formInt(form, "Days (correspondence)"),
+			Variant:   variant,
+			Color:     color,
+		}
+		if rangeMode != "any" {
+			req.RatingRange = lichess.RatingRange{
+				Min:      formInt(form, "Min rating"),
+				Max:      formInt(form, "Max rating"),
+				Relative: rangeMode == "relative",
+			}
+		}
+		if req.Days <= 0 && req.Time <= 0 {
+			form.SetTitle("The game needs at least one minute, or days for correspondence")
+			return
+		}
+		if req.RatingRange.Min > req.RatingRange.Max {
+			form.SetTitle("Min rating is above max rating")
+			return
+		}
+		onClose()
+		state.UIState().Seek <- req
+	})
+	form.AddButton("Back", onClose)
+
+	form.SetBorder(true).SetTitle("Custom seek")
+	return form
+}
+
+var aiLevels = []string{"1", "2", "3", "4", "5", "6", "7", "8"}
+
+func aiChallengeForm(state *MainState, onSubmit func(level int), onClose func()) *tview.Form {
+	form := tview.NewForm().
+		AddDropDown("Level", aiLevels, 0, nil).
+		AddInputField("Minutes", "15", 5, tview.InputFieldInteger, nil).
+		AddInputField("Increment", "10", 5, tview.InputFieldInteger, nil).
+		AddDropDown("Color", colorOptions, 0, nil)
+
+	form.AddButton("Play", func() {
+		levelIndex, _ := form.GetFormItemByLabel("Level").(*tview.DropDown).GetCurrentOption()
+		_, color := form.GetFormItemByLabel("Color").(*tview.DropDown).GetCurrentOption()
+
+		challenge := AIChallenge{
+			Level: levelIndex + 1,
+			Request: lichess.ChallengeRequest{
+				ClockLimit:     formInt(form, "Minutes") * 60,
+				ClockIncrement: formInt(form, "Increment"),
+				Color:          color,
+			},
+		}
+		onSubmit(challenge.Level)
+		onClose()
+		state.UIState().AIChallenge <- challenge
+	})
+	form.AddButton("Back", onClose)
+
+	form.SetBorder(true).SetTitle("Play the computer")
+	return form
+}
+
+// engineLevels are the skill levels of the local engine, from 0 to 20
+var engineLevels = func() []string {
+	levels := []string{}
+	for level := 0; level <= 20; level++ {
+		levels = append(levels, strconv.Itoa(level))
+	}
+	return levels
+}()
+
+func engineGameForm(state *MainState, onSubmit func(settings EngineGameSettings), onClose func()) *tview.Form {
+	form := tview.NewForm().
+		AddDropDown("Level", engineLevels, 5, nil).
+		AddInputField("Minutes", "15", 5, tview.InputFieldInteger, nil).
+		AddInputField("Increment", "10", 5, tview.InputFieldInteger, nil).
+		AddDropDown("Color", colorOptions, 0, nil)
+
+	form.AddButton("Play", func() {
+		level, _ := form.GetFormItemByLabel("Level").(*tview.DropDown).GetCurrentOption()
+		_, color := form.GetFormItemByLabel("Color").(*tview.DropDown).GetCurrentOption()
+
+		settings := EngineGameSettings{
+			Level:     level,
+			Color:     color,
+			Time:      formInt(form, "Minutes"),
+			Increment: formInt(form, "Increment"),
+		}
+		if settings.Time <= 0 {
+			form.SetTitle("The game needs at least one minute")
+			return
+		}
+		onSubmit(settings)
+		onClose()
+		state.UIState().StartLocalGame(settings)
+	})
+	form.AddButton("Back", onClose)
+
+	form.SetBorder(true).SetTitle("Play offline against " + state.Config().Engine.Path)
+	return form
+}
+
+var incrementModes = []string{string(FischerIncrement), string(BronsteinIncrement)}
+
+func otbGameForm(state *MainState, onSubmit func(settings OTBGameSettings), onClose func()) *tview.Form {
+	form := tview.NewForm().
+		AddInputField("White", "", 30, nil, nil).
+		AddInputField("Black", "", 30, nil, nil).
+		AddInputField("Event", "Casual game", 30, nil, nil).
+		AddInputField("Minutes", "15", 5, tview.InputFieldInteger, nil).
+		AddInputField("Increment", "10", 5, tview.InputFieldInteger, nil).
+		AddDropDown("Increment mode", incrementModes, 0, nil).
+		AddCheckbox("Arbiter", false, nil)
+
+	form.AddButton("Play", func() {
+		_, mode := form.GetFormItemByLabel("Increment mode").(*tview.DropDown).GetCurrentOption()
+
+		settings := OTBGameSettings{
+			Time:      formInt(form, "Minutes"),
+			Increment: formInt(form, "Increment"),
+			Mode:      IncrementMode(mode),
+			White:     formText(form, "White"),
+			Black:     formText(form, "Black"),
+			Event:     formText(form, "Event"),
+			Arbiter:   form.GetFormItemByLabel("Arbiter").(*tview.Checkbox).IsChecked(),
+		}
+		if settings.Time <= 0 {
+			form.SetTitle("The game needs at least one minute")
+			return
+		}
+		onSubmit(settings)
+		onClose()
+		state.UIState().StartLocalGame(settings)
+	})
+	form.AddButton("Back", onClose)
+
+	form.SetBorder(true).SetTitle("Play over the board")
+	return form
+}
+
+var pgnResults = []string{"1-0", "0-1", "1/2-1/2", "*"}
+
+// recordForm fills the headers of a game played over the board, and saves it in the archive. The PGN can also be exported to a file
+func recordForm(state *MainState, record *GameRecord, onClose func()) *tview.Form {
+	result := slices.Index(pgnResults, record.Headers.Result)
+	form := tview.NewForm().
+		AddInputField("White", record.Headers.White, 30, nil, nil).
+		AddInputField("Black", record.Headers.Black, 30, nil, nil).
+		AddInputField("Event", record.Headers.Event, 30, nil, nil).
+		AddInputField("Site", record.Headers.Site, 30, nil, nil).
+		AddInputField("Date", record.Headers.Date, 12, nil, nil).
+		AddDropDown("Result", pgnResults, max(result, 0), nil).
+		AddInputField("Export to", "", 40, nil, nil)
+
+	form.AddButton("Save", func() {
+		_, result := form.GetFormItemByLabel("Result").(*tview.DropDown).GetCurrentOption()
+		record.Headers = PGNHeaders{
+			White:  formText(form, "White"),
+			Black:  formText(form, "Black"),
+			Event:  formText(form, "Event"),
+			Site:   formText(form, "Site"),
+			Date:   formText(form, "Date"),
+			Result: result,
+		}
+		if err := saveRecord(state.Archive(), record, formText(form, "Export to")); err != nil {
+			log.Printf("Error saving game %s: %v", record.GameID, err)
+			form.SetTitle(fmt.Sprintf("Could not save the game: %v", err))
+			return
+		}
+		log.Printf("Game %s saved", record.GameID)
+		onClose()
+	})
+	form.AddButton("Discard", onClose)
+
+	title := fmt.Sprintf("Save the game (%d moves)", len(record.Moves))
+	if len(record.Incidents) > 0 {
+		title = fmt.Sprintf("Save the game (%d moves, %d arbiter incidents)", len(record.Moves), len(record.Incidents))
+	}
+	form.SetBorder(true).SetTitle(title)
+	return form
+}
+
+func formText(form *tview.Form, label string) string {
+	return form.GetFormItemByLabel(label).(*tview.InputField).GetText()
+}
+
+func formInt(form *tview.Form, label string) int {
+	value, err := strconv.Atoi(formText(form, label))
+	if err != nil {
+		return 0
+	}
+	return value
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/aherve/eChess/goapp/lichess"
+	"github.com/rivo/tview"
+)
+
+// gamesPage lets the player choose which of the ongoing games the board drives
+func gamesPage(state *MainState, onClose func()) *tview.Flex {
+	choose := func(gameId string) {
+		state.UIState().SelectGame(gameId)
+		onClose()
+	}
+
+	list := tview.NewList()
+	for _, game := range state.UIState().PlayingGames() {
+		list.AddItem(getGameText(game), getGameDetails(game), 0, func() {
+			choose(game.GameId)
+		})
+	}
+
+	form := tview.NewForm().
+		AddInputField("Game URL or ID", "", 40, nil, nil)
+	form.AddButton("Attach", func() {
+		gameId, err := lichess.ParseGameID(formText(form, "Game URL or ID"))
+		if err != nil {
+			form.SetTitle(err.Error())
+			return
+		}
+		// games missing from the list are checked with lichess by the backend
+		choose(gameId)
+	})
+	form.AddButton("Close", onClose)
+	form.SetBorder(true)
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(list, 0, 1, true).
+		AddItem(form, 7, 0, false)
+
+	layout.SetBorder(true).SetTitle("Choose a game")
+	return layout
+}
+
+func getGameText(game lichess.GameEvent) string {
+	return fmt.Sprintf("(%d) %s · %s", game.Opponent.Rating, game.Opponent.Username, game.Speed)
+}
+
+func getGameDetails(game lichess.GameEvent) string {
+	turn := "Their turn"
+	if game.IsMyTurn {
+		turn = "Your turn"
+	}
+	if game.LastMove == "" {
+		return fmt.Sprintf("%s, you play %s", turn, game.Color)
+	}
+	return fmt.Sprintf("%s, you play %s, last move %s", turn, game.Color, game.LastMove)
+}
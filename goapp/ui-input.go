@@ -12,6 +12,23 @@ const (
 	Seeking
 	StopSeeking
 	PromoteWhat
+	ChallengeReceived
+	ChallengeCanceled
+	ChallengeSent
+	ChallengeDeclined
+	ChallengeFailed
+	Unauthorized
+	TakebackProposed
+	TakenBack
+	DrawOffered
+	ChooseGame
+	OpponentReplied
+	CorrespondenceMoveSent
+	AbortDecided
+	OpponentLoaded
+	GameRecorded
+	ArbiterIncident
+	GameNotFound
 )
 
 func (i UIInput) String() string {
@@ -34,6 +51,40 @@ func (i UIInput) String() string {
 		return "StopSeeking"
 	case PromoteWhat:
 		return "PromoteWhat"
+	case ChallengeReceived:
+		return "ChallengeReceived"
+	case ChallengeCanceled:
+		return "ChallengeCanceled"
+	case ChallengeSent:
+		return "ChallengeSent"
+	case ChallengeDeclined:
+		return "ChallengeDeclined"
+	case ChallengeFailed:
+		return "ChallengeFailed"
+	case Unauthorized:
+		return "Unauthorized"
+	case TakebackProposed:
+		return "TakebackProposed"
+	case TakenBack:
+		return "TakenBack"
+	case DrawOffered:
+		return "DrawOffered"
+	case ChooseGame:
+		return "ChooseGame"
+	case OpponentReplied:
+		return "OpponentReplied"
+	case CorrespondenceMoveSent:
+		return "CorrespondenceMoveSent"
+	case AbortDecided:
+		return "AbortDecided"
+	case OpponentLoaded:
+		return "OpponentLoaded"
+	case GameRecorded:
+		return "GameRecorded"
+	case ArbiterIncident:
+		return "ArbiterIncident"
+	case GameNotFound:
+		return "GameNotFound"
 	default:
 		return "Unknown UIInput"
 	}
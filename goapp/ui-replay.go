@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/aherve/eChess/goapp/archive"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// archivePage lists the archived games, filtered by opponent. Selecting a game opens it for replay
+func archivePage(state *MainState, onSelect func(*Replay), onClose func()) *tview.Flex {
+	list := tview.NewList()
+	layout := tview.NewFlex().SetDirection(tview.FlexRow)
+
+	gameArchive := state.Archive()
+	refresh := func(opponent string) {
+		list.Clear()
+		if gameArchive == nil {
+			return
+		}
+		for _, entry := range gameArchive.Search(archive.Query{Opponent: opponent}) {
+			list.AddItem(getEntryText(entry), getEntryDetails(entry), 0, func() {
+				pgn, err := gameArchive.PGN(entry)
+				if err == nil {
+					var replay *Replay
+					if replay, err = NewReplay(entry, pgn); err == nil {
+						onSelect(replay)
+						return
+					}
+				}
+				layout.SetTitle(fmt.Sprintf("Cannot replay this game: %v", err))
+			})
+		}
+	}
+	refresh("")
+
+	form := tview.NewForm().
+		AddInputField("Opponent", "", 30, nil, refresh).
+		AddButton("Close", onClose)
+
+	layout.
+		AddItem(form, 5, 0, false).
+		AddItem(list, 0, 1, true)
+
+	layout.SetBorder(true).SetTitle("Replay a game")
+	if gameArchive == nil {
+		layout.SetTitle("The game archive is not available")
+	}
+	return layout
+}
+
+func getEntryText(entry archive.Entry) string {
+	return fmt.Sprintf("%s · %s · %s", entry.Date.Format("2006-01-02 15:04"), entry.Opponent, entry.Speed)
+}
+
+func getEntryDetails(entry archive.Entry) string {
+	return fmt.Sprintf("%s %s, %s", entry.Result, entry.Outcome, entry.Reason)
+}
+
+// replayPage shows the current move of the replay. The LEDs guide the board to the position after that move
+func replayPage(state *MainState, replay *Replay, onClose func()) *tview.Flex {
+	position := tview.NewTextView().
+		SetTextAlign(tview.AlignCenter)
+	move := tview.NewTextView().
+		SetTextAlign(tview.AlignCenter)
+	comment := tview.NewTextView().
+		SetTextAlign(tview.AlignCenter)
+
+	show := func() {
+		position.SetText(replay.Position().Board().Draw())
+		move.SetText(fmt.Sprintf("%s   (%d/%d)", replay.MoveText(), replay.Index(), replay.Len()))
+		comment.SetText(replay.Comment())
+		state.RefreshLEDs()
+	}
+	seek := func(index int) {
+		if replay.Seek(index) {
+			show()
+		}
+	}
+
+	btn := func(label string, onSelect func()) *tview.Button {
+		return tview.NewButton(label).SetSelectedFunc(onSelect)
+	}
+	buttons := tview.NewFlex().
+		AddItem(btn("⏮", func() { seek(0) }), 0, 1, false).
+		AddItem(tview.NewBox(), 1, 0, false).
+		AddItem(btn("◀", func() { seek(replay.Index() - 1) }), 0, 1, false).
+		AddItem(tview.NewBox(), 1, 0, false).
+		AddItem(btn("▶", func() { seek(replay.Index() + 1) }), 0, 1, false).
+		AddItem(tview.NewBox(), 1, 0, false).
+		AddItem(btn("⏭", func() { seek(replay.Len()) }), 0, 1, false).
+		AddItem(tview.NewBox(), 1, 0, false).
+		AddItem(btn("Close", onClose), 0, 1, false)
+
+	entry := replay.Entry()
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(position, 10, 0, false).
+		AddItem(move, 1, 0, false).
+		AddItem(comment, 2, 0, false).
+		AddItem(tview.NewBox(), 0, 1, false).
+		AddItem(buttons, 3, 0, true)
+	layout.SetBorder(true).SetTitle(fmt.Sprintf("%s against %s (%s)", entry.Date.Format("2006-01-02"), entry.Opponent, entry.Result))
+
+	// arrows navigate through the moves, home and end jump to the start and the end of the game
+	layout.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyRight:
+			seek(replay.Index() + 1)
+		case tcell.KeyLeft:
+			seek(replay.Index() - 1)
+		case tcell.KeyHome:
+			seek(0)
+		case tcell.KeyEnd:
+			seek(replay.Len())
+		default:
+			return event
+		}
+		return nil
+	})
+
+	show()
+	return layout
+}
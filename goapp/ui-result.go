@@ -0,0 +1,25 @@
+package main
+
+import (
+	"github.com/rivo/tview"
+)
+
+func resultPage(result *GameResult, onRematch, onNewOpponent, onClose func()) *tview.Flex {
+	summary := tview.NewTextView().
+		SetText(result.String()).
+		SetTextAlign(tview.AlignCenter)
+
+	buttons := tview.NewFlex().
+		AddItem(tview.NewButton("Rematch").SetSelectedFunc(onRematch), 0, 1, true).
+		AddItem(tview.NewBox(), 1, 0, false).
+		AddItem(tview.NewButton("New opponent").SetSelectedFunc(onNewOpponent), 0, 1, false).
+		AddItem(tview.NewBox(), 1, 0, false).
+		AddItem(tview.NewButton("OK").SetSelectedFunc(onClose), 0, 1, false)
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(summary, 0, 1, false).
+		AddItem(buttons, 3, 0, true)
+
+	layout.SetBorder(true).SetTitle("Game over")
+	return layout
+}
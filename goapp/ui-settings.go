@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/rivo/tview"
+)
+
+func settingsPage(state *MainState, onEditPreset func(index int), onClose func()) *tview.List {
+	list := tview.NewList()
+
+	for i, preset := range state.Config().SeekPresets() {
+		list.AddItem(preset.Label, presetDescription(preset), 0, func() {
+			onEditPreset(i)
+		})
+	}
+	list.AddItem("Add preset", "", '+', func() {
+		onEditPreset(-1)
+	})
+	list.AddItem("Back", "", 'q', onClose)
+
+	list.SetBorder(true).SetTitle("Seek presets")
+	return list
+}
+
+// presetForm edits the preset at the given index, or creates a new one if index is -1
+func presetForm(state *MainState, index int, onSave func(), onClose func()) *tview.Form {
+	presets := slices.Clone(state.Config().SeekPresets())
+
+	preset := SeekPreset{Time: 15, Increment: 10, Rated: true}
+	if index >= 0 {
+		preset = presets[index]
+	}
+
+	rangeMode := 0
+	if preset.RatingMin != 0 || preset.RatingMax != 0 {
+		rangeMode = 1
+		if preset.RelativeRating {
+			rangeMode = 2
+		}
+	}
+
+	form := tview.NewForm().
+		AddInputField("Label", preset.Label, 12, nil, nil).
+		AddInputField("Minutes", fmt.Sprintf("%d", preset.Time), 5, tview.InputFieldInteger, nil).
+		AddInputField("Increment", fmt.Sprintf("%d", preset.Increment), 5, tview.InputFieldInteger, nil).
+		AddCheckbox("Rated", preset.Rated, nil).
+		AddDropDown("Rating range", ratingRangeOptions, rangeMode, nil).
+		AddInputField("Min rating", fmt.Sprintf("%d", preset.RatingMin), 6, tview.InputFieldInteger, nil).
+		AddInputField("Max rating", fmt.Sprintf("%d", preset.RatingMax), 6, tview.InputFieldInteger, nil)
+
+	save := func(presets []SeekPreset) {
+		if err := state.Config().SetSeekPresets(presets); err != nil {
+			log.Printf("Error saving seek presets: %v", err)
+		}
+		onSave()
+	}
+
+	form.AddButton("Save", func() {
+		_, mode := form.GetFormItemByLabel("Rating range").(*tview.DropDown).GetCurrentOption()
+
+		edited := SeekPreset{
+			Label:     formText(form, "Label"),
+			Time:      formInt(form, "Minutes"),
+			Increment: formInt(form, "Increment"),
+			Rated:     form.GetFormItemByLabel("Rated").(*tview.Checkbox).IsChecked(),
+		}
+		if mode != "any" {
+			edited.RatingMin = formInt(form, "Min rating")
+			edited.RatingMax = formInt(form, "Max rating")
+			edited.RelativeRating = mode == "relative"
+		}
+		if edited.Label == "" {
+			edited.Label = fmt.Sprintf("%d|%d", edited.Time, edited.Increment)
+		}
+
+		if index >= 0 {
+			presets[index] = edited
+		} else {
+			presets = append(presets, edited)
+		}
+		save(presets)
+	})
+	if index >= 0 {
+		form.AddButton("Delete", func() {
+			save(slices.Delete(presets, index, index+1))
+		})
+	}
+	form.AddButton("Cancel", onClose)
+
+	form.SetBorder(true).SetTitle("Seek preset")
+	return form
+}
+
+func presetDescription(preset SeekPreset) string {
+	description := preset.SeekRequest().String()
+	if preset.RatingMin == 0 && preset.RatingMax == 0 {
+		return description
+	}
+	if preset.RelativeRating {
+		return fmt.Sprintf("%s, rating %+d/%+d", description, preset.RatingMin, preset.RatingMax)
+	}
+	return fmt.Sprintf("%s, rating %d-%d", description, preset.RatingMin, preset.RatingMax)
+}
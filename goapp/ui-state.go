@@ -9,20 +9,57 @@ import (
 	"github.com/aherve/eChess/goapp/lichess"
 )
 
+type FriendChallenge struct {
+	Username string
+	Request  lichess.ChallengeRequest
+}
+
+type AIChallenge struct {
+	Level   int
+	Request lichess.ChallengeRequest
+}
+
 type UIState struct {
-	Input   chan UIInput  // System talking to the UI
-	Output  chan UIOutput // UI talking to the system
-	Promote chan Promotion
+	Input           chan UIInput  // System talking to the UI
+	Output          chan UIOutput // UI talking to the system
+	Promote         chan Promotion
+	FriendChallenge chan FriendChallenge
+	AIChallenge     chan AIChallenge
+	Seek            chan lichess.SeekRequest
+	gameChosen      chan bool // wakes the backend up when a game is selected
 
-	cancelSeek *context.CancelFunc
-	mu         sync.Mutex
+	cancelSeek       *context.CancelFunc
+	pendingChallenge *lichess.Challenge
+	lastResult       *GameResult
+	lastSeek         *lichess.SeekRequest
+	playingGames     []lichess.GameEvent
+	selectedGame     string
+	// in correspondence mode, the board cycles through the correspondence games waiting for our move
+	correspondenceMode bool
+	repliedGames       []lichess.GameEvent
+	abortDecision      *AbortDecision
+	opponentProfile    *OpponentProfile
+	// over the board game waiting for its headers to be saved
+	pendingRecord *GameRecord
+	// last incident noticed by the arbiter during an over the board game
+	lastIncident *Incident
+	// offline game requested from the UI, started by the backend
+	pendingLocalGame LocalGameSettings
+	lastLocalGame    LocalGameSettings
+	mu               sync.Mutex
+	// one seek or challenge is created at a time. mu is not held meanwhile, as the UI reads it while being notified
+	seekMu sync.Mutex
 }
 
 func NewUIState() *UIState {
 	return &UIState{
-		Input:   make(chan UIInput),
-		Output:  make(chan UIOutput),
-		Promote: make(chan Promotion),
+		Input:           make(chan UIInput),
+		Output:          make(chan UIOutput),
+		Promote:         make(chan Promotion),
+		FriendChallenge: make(chan FriendChallenge),
+		AIChallenge:     make(chan AIChallenge),
+		Seek:            make(chan lichess.SeekRequest),
+		gameChosen:      make(chan bool, 1),
 	}
 }
 
@@ -40,6 +77,46 @@ func (s *UIState) ClearSeek() {
 	s.cancelSeek = nil
 }
 
+func (s *UIState) LastResult() *GameResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lastResult
+}
+
+func (s *UIState) SetLastResult(result *GameResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastResult = result
+}
+
+func (s *UIState) PendingChallenge() *lichess.Challenge {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.pendingChallenge
+}
+
+func (s *UIState) SetPendingChallenge(challenge *lichess.Challenge) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pendingChallenge = challenge
+}
+
+// ClearChallenge forgets the pending challenge if it matches the given id, and returns whether it did
+func (s *UIState) ClearChallenge(challengeId string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pendingChallenge == nil || s.pendingChallenge.ID != challengeId {
+		return false
+	}
+	s.pendingChallenge = nil
+	return true
+}
+
 func (s *UIState) CancelSeek() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -54,20 +131,247 @@ func (s *UIState) CancelSeek() {
 
 }
 
-func (s *UIState) CreateSeek(gameTime, increment string) {
+func (s *UIState) PlayingGames() []lichess.GameEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.playingGames
+}
+
+// SetPlayingGames stores our ongoing games, and returns whether the list of games changed
+func (s *UIState) SetPlayingGames(games []lichess.GameEvent) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := len(games) != len(s.playingGames)
+	for i := range games {
+		if changed {
+			break
+		}
+		changed = games[i].GameId != s.playingGames[i].GameId
+	}
+	s.playingGames = games
+	return changed
+}
+
+func (s *UIState) SelectedGame() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.selectedGame
+}
+
+// SelectGame picks the game the board should drive, by its 8 characters id
+func (s *UIState) SelectGame(gameId string) {
+	s.mu.Lock()
+	s.selectedGame = gameId
+	s.mu.Unlock()
+
+	if gameId == "" {
+		return
+	}
+	select {
+	case s.gameChosen <- true:
+	default:
+	}
+}
+
+func (s *UIState) CorrespondenceMode() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.correspondenceMode
+}
+
+func (s *UIState) SetCorrespondenceMode(enabled bool) {
+	s.mu.Lock()
+	s.correspondenceMode = enabled
+	s.mu.Unlock()
+
+	select {
+	case s.gameChosen <- true:
+	default:
+	}
+}
+
+// RepliedGames returns the correspondence games where the opponent replied most recently
+func (s *UIState) RepliedGames() []lichess.GameEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.repliedGames
+}
+
+func (s *UIState) SetRepliedGames(games []lichess.GameEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.repliedGames = games
+}
+
+func (s *UIState) AbortDecision() *AbortDecision {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.abortDecision
+}
+
+func (s *UIState) SetAbortDecision(decision *AbortDecision) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.abortDecision = decision
+}
+
+func (s *UIState) OpponentProfile() *OpponentProfile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.opponentProfile
+}
+
+func (s *UIState) SetOpponentProfile(profile *OpponentProfile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.opponentProfile = profile
+}
+
+func (s *UIState) PendingRecord() *GameRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.pendingRecord
+}
+
+func (s *UIState) SetPendingRecord(record *GameRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pendingRecord = record
+}
+
+func (s *UIState) LastIncident() *Incident {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lastIncident
+}
+
+func (s *UIState) SetLastIncident(incident *Incident) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastIncident = incident
+}
+
+// StartLocalGame asks the backend to start an offline game
+func (s *UIState) StartLocalGame(settings LocalGameSettings) {
+	s.mu.Lock()
+	s.pendingLocalGame = settings
+	s.lastLocalGame = settings
+	s.mu.Unlock()
+
+	select {
+	case s.gameChosen <- true:
+	default:
+	}
+}
+
+// TakeLocalGame returns the offline game waiting to be started, if any
+func (s *UIState) TakeLocalGame() LocalGameSettings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	settings := s.pendingLocalGame
+	s.pendingLocalGame = nil
+	return settings
+}
+
+// LastLocalGame returns the settings of the last offline game, to offer a rematch
+func (s *UIState) LastLocalGame() LocalGameSettings {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	return s.lastLocalGame
+}
+
+// WaitForGameChoice sleeps for the given duration, or until a game is selected, an offline game is requested or the correspondence mode changes
+func (s *UIState) WaitForGameChoice(timeout time.Duration) {
+	select {
+	case <-s.gameChosen:
+	case <-time.After(timeout):
+	}
+}
+
+func (s *UIState) LastSeek() *lichess.SeekRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lastSeek
+}
+
+func (s *UIState) CreateSeek(ctx context.Context, backend GameBackend, req lichess.SeekRequest) {
+	s.seekMu.Lock()
+	defer s.seekMu.Unlock()
+
+	s.mu.Lock()
+	s.lastSeek = &req
+	existingCancel := s.cancelSeek
+	s.cancelSeek = nil
+	s.mu.Unlock()
+
 	s.Input <- Seeking
 
-	if existingCancel := s.cancelSeek; existingCancel != nil {
+	if existingCancel != nil {
 		log.Println("Canceling previous seek")
 		(*existingCancel)()
-		s.cancelSeek = nil
 		log.Println("Previous seek cancelled")
 		time.Sleep(200 * time.Millisecond) // don't spam lichess
 	}
 
-	s.cancelSeek = lichess.CreateSeek(gameTime, increment)
+	cancelSeek := backend.Seek(ctx, req)
+	s.mu.Lock()
+	s.cancelSeek = cancelSeek
+	s.mu.Unlock()
+
+	if cancelSeek == nil {
+		s.Input <- StopSeeking
+	}
+}
+
+// ChallengeFriend challenges a lichess user. Like a seek, the challenge can be cancelled until the opponent answers
+func (s *UIState) ChallengeFriend(parent context.Context, challenge FriendChallenge) {
+	s.seekMu.Lock()
+	defer s.seekMu.Unlock()
+
+	s.Input <- ChallengeSent
+
+	ctx, cancel := context.WithCancel(parent)
+	s.mu.Lock()
+	existingCancel := s.cancelSeek
+	s.cancelSeek = &cancel
+	s.mu.Unlock()
+
+	if existingCancel != nil {
+		log.Println("Canceling previous seek")
+		(*existingCancel)()
+		time.Sleep(200 * time.Millisecond) // don't spam lichess
+	}
 
+	go func() {
+		status, err := lichess.ChallengeUser(ctx, challenge.Username, challenge.Request)
+		if ctx.Err() != nil {
+			// cancelled by the user, or shutting down
+			return
+		}
+		if err != nil {
+			log.Println(err)
+		}
+		// once accepted, the game is picked up by the backend
+		if status != "accepted" {
+			s.ClearSeek()
+			s.Input <- ChallengeDeclined
+		}
+	}()
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/aherve/eChess/goapp/lichess"
@@ -17,7 +18,112 @@ func runUI(state *MainState) {
 	tview.Styles.PrimitiveBackgroundColor = tcell.ColorDefault
 
 	app := tview.NewApplication()
-	seekButtons := seekButtons(state)
+	pages := tview.NewPages()
+
+	seekingPage, seekingTitle := seekingPage(state)
+
+	var seekTitle *tview.TextView
+	var refreshPresets func()
+	openFriendForm := func() {
+		pages.AddPage("friend", friendChallengeForm(state,
+			func(username string) {
+				seekingTitle.SetText(fmt.Sprintf("Waiting for %s to accept...", username))
+			},
+			func() {
+				pages.RemovePage("friend")
+			}), true, true)
+	}
+	openAIForm := func() {
+		pages.AddPage("ai", aiChallengeForm(state,
+			func(level int) {
+				seekTitle.SetText(fmt.Sprintf("Starting a game against Stockfish level %d...", level))
+			},
+			func() {
+				pages.RemovePage("ai")
+			}), true, true)
+	}
+	openEngineForm := func() {
+		pages.AddPage("offline", engineGameForm(state,
+			func(settings EngineGameSettings) {
+				seekTitle.SetText(fmt.Sprintf("Starting an offline game at level %d...", settings.Level))
+			},
+			func() {
+				pages.RemovePage("offline")
+			}), true, true)
+	}
+	openOTBForm := func() {
+		pages.AddPage("otb", otbGameForm(state,
+			func(settings OTBGameSettings) {
+				seekTitle.SetText(fmt.Sprintf("Starting a %d+%d game over the board...", settings.Time, settings.Increment))
+			},
+			func() {
+				pages.RemovePage("otb")
+			}), true, true)
+	}
+	openCustomSeekForm := func() {
+		pages.AddPage("customSeek", customSeekForm(state, func() {
+			pages.RemovePage("customSeek")
+		}), true, true)
+	}
+	var openSettings func()
+	openPresetForm := func(index int) {
+		pages.AddPage("preset", presetForm(state, index,
+			func() {
+				refreshPresets()
+				pages.RemovePage("preset")
+				pages.RemovePage("settings")
+				openSettings()
+			},
+			func() {
+				pages.RemovePage("preset")
+			}), true, true)
+	}
+	openSettings = func() {
+		pages.AddPage("settings", settingsPage(state, openPresetForm, func() {
+			pages.RemovePage("settings")
+		}), true, true)
+	}
+	closeReplay := func() {
+		state.SetReplay(nil)
+		state.ResetLitSquares()
+		pages.RemovePage("replay")
+		pages.RemovePage("archive")
+	}
+	openArchive := func() {
+		pages.AddPage("archive", archivePage(state,
+			func(replay *Replay) {
+				state.SetReplay(replay)
+				pages.AddPage("replay", replayPage(state, replay, closeReplay), true, true)
+			},
+			func() {
+				pages.RemovePage("archive")
+			}), true, true)
+	}
+	openGames := func() {
+		pages.RemovePage("games")
+		pages.AddPage("games", gamesPage(state, func() {
+			pages.RemovePage("games")
+		}), true, true)
+	}
+	toggleCorrespondence := func() {
+		if state.UIState().CorrespondenceMode() {
+			seekTitle.SetText("Ready for a new game")
+		} else {
+			seekTitle.SetText("Correspondence mode: waiting for your opponents to move")
+		}
+		state.UIState().Output <- ToggleCorrespondence
+	}
+	seekButtons, seekTitle, refreshPresets := seekButtons(state, []menuButton{
+		{"Custom seek", openCustomSeekForm},
+		{"Challenge a friend", openFriendForm},
+		{"Play the computer", openAIForm},
+		{"Play offline", openEngineForm},
+		{"Over the board", openOTBForm},
+		{"Games", openGames},
+		{"Correspondence", toggleCorrespondence},
+		{"Replay", openArchive},
+		{"Settings", openSettings},
+	})
 
 	currentBoard, boardState, currentBoardTitle := buildCurrentBoard(state)
 
@@ -34,8 +140,13 @@ func runUI(state *MainState) {
 	opponentClock := tview.NewTextView().
 		SetTextAlign(tview.AlignRight)
 
+	// outcome of the auto-abort rules for the current opponent, or the last arbiter incident over the board
+	abortInfo := tview.NewTextView().
+		SetTextAlign(tview.AlignRight)
+
 	topBar := tview.NewFlex().
 		AddItem(opponentName, 0, 3, false).
+		AddItem(abortInfo, 0, 2, false).
 		AddItem(opponentClock, 10, 0, false)
 
 	topBar.SetBorder(true)
@@ -50,17 +161,35 @@ func runUI(state *MainState) {
 
 	middleBar.SetBorder(true)
 
-	bottomBar := btnActions(state.UIState().Output)
+	// Opponent profile, collapsed until the player expands it
+	opponentPanel := tview.NewTextView().
+		SetText("Loading opponent profile...")
+	opponentPanel.SetBorder(true).SetTitle("Opponent")
+	opponentPanel.SetBorderPadding(0, 0, 2, 2)
+
+	bottomBar, drawButton := btnActions(state.UIState().Output)
 
 	playLayout := tview.NewFlex().SetDirection(tview.FlexRow).
 		AddItem(topBar, 5, 0, false).
+		AddItem(opponentPanel, 0, 0, false).
 		AddItem(middleBar, 5, 0, false).
 		AddItem(tview.NewBox(), 0, 1, false). // spacer
 		AddItem(bottomBar, 3, 0, false)
 
-	seekingPage := seekingPage(state)
+	profileExpanded := false
+	profileButton := tview.NewButton("Profile").SetSelectedFunc(func() {
+		profileExpanded = !profileExpanded
+		if profileExpanded {
+			playLayout.ResizeItem(opponentPanel, 6, 0)
+		} else {
+			playLayout.ResizeItem(opponentPanel, 0, 0)
+		}
+	})
+	bottomBar.
+		AddItem(tview.NewBox(), 1, 0, false).
+		AddItem(profileButton, 0, 1, false)
 
-	pages := tview.NewPages().
+	pages.
 		AddPage("seek", seekButtons, true, false).
 		AddPage("seeking", seekingPage, true, false).
 		AddPage("play", playLayout, true, false).
@@ -90,6 +219,65 @@ func runUI(state *MainState) {
 		})
 	}
 
+	// showModal displays a modal on top of the current page. onDone receives the selected button label
+	showModal := func(name, text string, buttons []string, onDone func(label string)) {
+		modal := tview.NewModal().
+			SetText(text).
+			AddButtons(buttons).
+			SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+				onDone(buttonLabel)
+				pages.RemovePage(name)
+			})
+		app.QueueUpdateDraw(func() {
+			pages.AddPage(name, modal, true, true)
+		})
+	}
+
+	openTakebackModal := func() {
+		showModal("takeback", "Your opponent proposes a takeback", []string{"Accept", "Decline"}, func(label string) {
+			switch label {
+			case "Accept":
+				state.UIState().Output <- AcceptTakeback
+			case "Decline":
+				state.UIState().Output <- DeclineTakeback
+			}
+		})
+	}
+
+	openDrawModal := func() {
+		showModal("draw", "Your opponent offers a draw", []string{"Accept", "Decline"}, func(label string) {
+			switch label {
+			case "Accept":
+				state.UIState().Output <- AcceptDraw
+			case "Decline":
+				state.UIState().Output <- DeclineDraw
+			}
+		})
+	}
+
+	openChallengeModal := func() {
+		challenge := state.UIState().PendingChallenge()
+		if challenge == nil {
+			return
+		}
+
+		modal := tview.NewModal().
+			SetText(getChallengeText(challenge)).
+			AddButtons([]string{"Accept", "Decline"}).
+			SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+				switch buttonLabel {
+				case "Accept":
+					state.UIState().Output <- AcceptChallenge
+				case "Decline":
+					state.UIState().Output <- DeclineChallenge
+				}
+				pages.RemovePage("challenge")
+			})
+		app.QueueUpdateDraw(func() {
+			pages.AddPage("challenge", modal, true, true)
+		})
+	}
+
 	// handle input events
 	go func() {
 		for {
@@ -100,6 +288,9 @@ func runUI(state *MainState) {
 
 					if !state.UIState().IsSeeking() {
 						app.QueueUpdateDraw(func() {
+							if hasOverlay(pages) {
+								return
+							}
 							boardState.SetText(state.Board().String())
 							if state.Board().IsStartingPosition() {
 								pages.HidePage("currentBoard")
@@ -140,6 +331,16 @@ func runUI(state *MainState) {
 					toUpdateWithFixed.SetText(displayTime(fixedTime))
 
 					opponentName.SetText(getOpponentText(state.Game()))
+					playerName.SetText(getPlayerText(state))
+					drawButton.SetLabel(getDrawLabel(state.Game()))
+
+					// offers are withdrawn when the opponent moves
+					if !state.Game().OpponentOffersDraw() && pages.HasPage("draw") {
+						pages.RemovePage("draw")
+					}
+					if !state.Game().OpponentProposesTakeback() && pages.HasPage("takeback") {
+						pages.RemovePage("takeback")
+					}
 
 				})
 			case input := <-state.UIState().Input:
@@ -147,46 +348,113 @@ func runUI(state *MainState) {
 				switch input {
 				case PromoteWhat:
 					go openPromoteModal()
-				case GameStarted:
+				case ChooseGame:
+					app.QueueUpdateDraw(openGames)
+				case GameNotFound:
 					app.QueueUpdateDraw(func() {
-						pages.HidePage("seek")
-						pages.HidePage("seeking")
-						pages.ShowPage("play")
-						pages.HidePage("currentBoard")
-						opponentName.SetText(getOpponentText(state.Game()))
-						playerName.SetText("You play " + state.Game().Color())
+						seekTitle.SetText("Could not attach the game: it is not one of your ongoing games")
 					})
-				case GameWon:
+				case AbortDecided:
 					app.QueueUpdateDraw(func() {
-						pages.HidePage("play")
-						pages.HidePage("seek")
-						pages.HidePage("seeking")
-						pages.ShowPage("currentBoard")
-						currentBoardTitle.SetText("Victory !")
+						if decision := state.UIState().AbortDecision(); decision != nil {
+							abortInfo.SetText(getAbortText(decision))
+						}
+					})
+				case ArbiterIncident:
+					app.QueueUpdateDraw(func() {
+						if incident := state.UIState().LastIncident(); incident != nil {
+							abortInfo.SetText("⚖ " + incident.String())
+						}
+					})
+				case GameRecorded:
+					app.QueueUpdateDraw(func() {
+						record := state.UIState().PendingRecord()
+						if record == nil {
+							return
+						}
+						pages.AddPage("record", recordForm(state, record, func() {
+							state.UIState().SetPendingRecord(nil)
+							pages.RemovePage("record")
+						}), true, true)
+					})
+				case OpponentLoaded:
+					app.QueueUpdateDraw(func() {
+						if profile := state.UIState().OpponentProfile(); profile != nil {
+							opponentPanel.SetText(profile.String())
+						}
+					})
+				case OpponentReplied:
+					app.QueueUpdateDraw(func() {
+						text := getRepliedText(state.UIState().RepliedGames())
+						seekTitle.SetText(text)
+						currentBoardTitle.SetText(text)
 					})
-				case GameLost:
+				case CorrespondenceMoveSent:
 					app.QueueUpdateDraw(func() {
+						currentBoardTitle.SetText("Waiting for your opponent, looking for the next correspondence game")
 						pages.HidePage("play")
-						pages.HidePage("seek")
-						pages.HidePage("seeking")
 						pages.ShowPage("currentBoard")
-						currentBoardTitle.SetText("Looooooose")
 					})
-				case GameAborted:
+				case DrawOffered:
+					go openDrawModal()
+				case TakebackProposed:
+					go openTakebackModal()
+				case TakenBack:
 					app.QueueUpdateDraw(func() {
-						pages.HidePage("play")
+						pages.RemovePage("takeback")
+						playerName.SetText(getPlayerText(state))
+					})
+				case ChallengeReceived:
+					go openChallengeModal()
+				case ChallengeCanceled:
+					app.QueueUpdateDraw(func() {
+						pages.RemovePage("challenge")
+					})
+				case GameStarted:
+					app.QueueUpdateDraw(func() {
+						if state.Replay() != nil {
+							closeReplay()
+						}
+						seekTitle.SetText("Ready for a new game")
 						pages.HidePage("seek")
 						pages.HidePage("seeking")
-						pages.ShowPage("currentBoard")
-						currentBoardTitle.SetText("Game aborted")
+						pages.ShowPage("play")
+						pages.HidePage("currentBoard")
+						opponentName.SetText(getOpponentText(state.Game()))
+						playerName.SetText(getPlayerText(state))
+						abortInfo.SetText("")
+						opponentPanel.SetText(getOpponentPanelText(state.Game()))
 					})
-				case GameDrawn:
+				case GameWon, GameLost, GameAborted, GameDrawn:
 					app.QueueUpdateDraw(func() {
 						pages.HidePage("play")
 						pages.HidePage("seek")
 						pages.HidePage("seeking")
 						pages.ShowPage("currentBoard")
-						currentBoardTitle.SetText("It's a draw ¯\\_(ツ)_/¯")
+
+						result := state.UIState().LastResult()
+						if result == nil {
+							return
+						}
+						currentBoardTitle.SetText(result.Title())
+						pages.AddPage("result", resultPage(result,
+							func() {
+								pages.RemovePage("result")
+								seekingTitle.SetText(fmt.Sprintf("Waiting for %s to accept the rematch...", result.Opponent.Username))
+								state.UIState().Output <- Rematch
+							},
+							func() {
+								pages.RemovePage("result")
+								state.UIState().Output <- NewOpponent
+							},
+							func() {
+								pages.RemovePage("result")
+							}), true, true)
+					})
+				case Unauthorized:
+					app.QueueUpdateDraw(func() {
+						currentBoardTitle.SetText("Lichess rejected the API token. Check secret.json")
+						seekTitle.SetText("Lichess rejected the API token. Check secret.json")
 					})
 				case NoCurrentGame:
 					app.QueueUpdateDraw(func() {
@@ -195,6 +463,15 @@ func runUI(state *MainState) {
 						}
 					})
 				case Seeking:
+					app.QueueUpdateDraw(func() {
+						seekingTitle.SetText("Seeking game...")
+						pages.HidePage("play")
+						pages.HidePage("seek")
+						pages.HidePage("currentBoard")
+
+						pages.ShowPage("seeking")
+					})
+				case ChallengeSent:
 					app.QueueUpdateDraw(func() {
 						pages.HidePage("play")
 						pages.HidePage("seek")
@@ -202,6 +479,16 @@ func runUI(state *MainState) {
 
 						pages.ShowPage("seeking")
 					})
+				case ChallengeDeclined:
+					app.QueueUpdateDraw(func() {
+						seekTitle.SetText("Your challenge was declined")
+						pages.HidePage("seeking")
+						pages.ShowPage("seek")
+					})
+				case ChallengeFailed:
+					app.QueueUpdateDraw(func() {
+						seekTitle.SetText("Could not start the game")
+					})
 				case StopSeeking:
 					app.QueueUpdateDraw(func() {
 						pages.HidePage("seeking")
@@ -257,25 +544,52 @@ func buildCurrentBoard(state *MainState) (*tview.Flex, *tview.TextView, *tview.T
 	return layout, board, boardTitle
 }
 
-func seekButtons(state *MainState) *tview.Flex {
+type menuButton struct {
+	label    string
+	onSelect func()
+}
 
-	// Rows with horizontal spacing
-	row1 := tview.NewFlex().
-		AddItem(makeBtn("15|10", Seek1510, state.UIState().Output), 0, 1, false).
-		AddItem(tview.NewBox(), 1, 0, false).
-		AddItem(makeBtn("15|30", Seek1530, state.UIState().Output), 0, 1, false)
+func seekButtons(state *MainState, menu []menuButton) (*tview.Flex, *tview.TextView, func()) {
+
+	// Grid of preset buttons, rebuilt whenever the presets change
+	presetGrid := tview.NewFlex().
+		SetDirection(tview.FlexRow)
+
+	refreshPresets := func() {
+		presetGrid.Clear()
+
+		presets := state.Config().SeekPresets()
+		for i := 0; i < len(presets); i += 2 {
+			// Rows with horizontal spacing
+			row := tview.NewFlex().
+				AddItem(makeSeekBtn(presets[i], state.UIState().Seek), 0, 1, false).
+				AddItem(tview.NewBox(), 1, 0, false)
+			if i+1 < len(presets) {
+				row.AddItem(makeSeekBtn(presets[i+1], state.UIState().Seek), 0, 1, false)
+			} else {
+				row.AddItem(tview.NewBox(), 0, 1, false)
+			}
 
-	row2 := tview.NewFlex().
-		AddItem(makeBtn("30|20", Seek3020, state.UIState().Output), 0, 1, false).
-		AddItem(tview.NewBox(), 1, 0, false).
-		AddItem(makeBtn("30|30", Seek3030, state.UIState().Output), 0, 1, false)
+			presetGrid.
+				AddItem(row, 3, 0, false).
+				AddItem(tview.NewBox(), 1, 0, false)
+		}
+	}
+	refreshPresets()
+
+	menuRow := tview.NewFlex()
+	for i, btn := range menu {
+		if i > 0 {
+			menuRow.AddItem(tview.NewBox(), 1, 0, false)
+		}
+		menuRow.AddItem(tview.NewButton(btn.label).SetSelectedFunc(btn.onSelect), 0, 1, false)
+	}
 
 	// Grid of buttons with vertical spacing
 	buttonGrid := tview.NewFlex().
 		SetDirection(tview.FlexRow).
-		AddItem(row1, 3, 0, false).
-		AddItem(tview.NewBox(), 1, 0, false).
-		AddItem(row2, 3, 0, false)
+		AddItem(presetGrid, 0, 1, false).
+		AddItem(menuRow, 3, 0, false)
 
 	// Title text
 	seekTitle := tview.NewTextView().
@@ -295,10 +609,10 @@ func seekButtons(state *MainState) *tview.Flex {
 		AddItem(tview.NewBox(), 1, 0, false). // spacing under title
 		AddItem(centeredButtons, 0, 1, true)
 
-	return layout
+	return layout, seekTitle, refreshPresets
 }
 
-func seekingPage(state *MainState) *tview.Flex {
+func seekingPage(state *MainState) (*tview.Flex, *tview.TextView) {
 	// Title text
 	title := tview.NewTextView().
 		SetText("Seeking game...").
@@ -326,24 +640,39 @@ func seekingPage(state *MainState) *tview.Flex {
 			AddItem(nil, 0, 1, false), 30, 1, true).
 		AddItem(nil, 0, 1, false)
 
-	return centered
+	return centered, title
 }
 
-func btnActions(c chan UIOutput) *tview.Flex {
-	// create a flex layout with three buttons
+// Overlays are opened on top of the other pages, and must not be hidden by the periodic refresh
+var overlayPages = []string{"customSeek", "friend", "ai", "settings", "preset", "result", "games", "archive", "replay", "offline", "otb", "record"}
+
+func hasOverlay(pages *tview.Pages) bool {
+	for _, name := range overlayPages {
+		if pages.HasPage(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func btnActions(c chan UIOutput) (*tview.Flex, *tview.Button) {
+	// create a flex layout with the game buttons
 	btn := func(label string, action UIOutput) *tview.Button {
 		return tview.NewButton(label).SetSelectedFunc(func() {
 			c <- action
 		})
 	}
+	drawButton := btn("Draw", Draw)
 	flex := tview.NewFlex().
 		AddItem(btn("Resign", Resign), 0, 1, false).
 		AddItem(tview.NewBox(), 1, 0, false).
-		AddItem(btn("Draw", Draw), 0, 1, false).
+		AddItem(drawButton, 0, 1, false).
+		AddItem(tview.NewBox(), 1, 0, false).
+		AddItem(btn("Takeback", ProposeTakeback), 0, 1, false).
 		AddItem(tview.NewBox(), 1, 0, false).
 		AddItem(btn("Abort", Abort), 0, 1, false)
 
-	return flex
+	return flex, drawButton
 }
 
 func displayTimeElapsed(clockUpdatedAt time.Time, wbTime int) string {
@@ -364,17 +693,91 @@ func displayTime(millis int) string {
 	return fmt.Sprintf("%02d:%02d", minutes, seconds)
 }
 
+func getPlayerText(state *MainState) string {
+	if state.AwaitingSync() {
+		if state.Game().Speed() == lichess.Correspondence {
+			return "✉ Set up the position shown by the LEDs"
+		}
+		if len(state.Game().Moves()) == 0 {
+			return "Set up the initial position shown by the LEDs"
+		}
+		return "↩ Takeback: restore the position shown by the LEDs"
+	}
+	if move := state.IllegalMove(); move != "" {
+		return fmt.Sprintf("⚠ Illegal move %s, put the piece back", move)
+	}
+	if state.Game().Variant() == "chess960" && len(state.Game().Moves()) == 0 {
+		return "Chess960, set up your pieces from a to h: " + getBackRank(state.Game())
+	}
+	if state.Game().MyDrawOfferPending() {
+		return "🤝 Draw offer sent"
+	}
+	if game, ok := state.Backend().(*OTBGame); ok {
+		return orDefault(game.Settings().White, "White")
+	}
+	return "You play " + state.Game().Color()
+}
+
+// getBackRank lists the white pieces of the first rank, which black mirrors in Chess960
+func getBackRank(g *lichess.Game) string {
+	board := g.ChessGame().Position().Board()
+	pieces := []string{}
+	for f := chess.FileA; f <= chess.FileH; f++ {
+		pieces = append(pieces, board.Piece(chess.NewSquare(f, chess.Rank1)).Type().String())
+	}
+	return strings.ToUpper(strings.Join(pieces, " "))
+}
+
+func getOpponentPanelText(g *lichess.Game) string {
+	if level := g.Opponent().AI; level > 0 {
+		return fmt.Sprintf("Stockfish level %d", level)
+	}
+	return "Loading opponent profile..."
+}
+
+func getAbortText(decision *AbortDecision) string {
+	if decision.Abort {
+		return "✗ Aborting: " + decision.Reason
+	}
+	return "✓ No abort rule matched"
+}
+
+func getRepliedText(games []lichess.GameEvent) string {
+	if len(games) == 1 {
+		return fmt.Sprintf("✉ %s replied in your correspondence game", games[0].Opponent.Username)
+	}
+	return fmt.Sprintf("✉ Your opponents replied in %d correspondence games", len(games))
+}
+
+func getDrawLabel(g *lichess.Game) string {
+	switch {
+	case g.CanClaimDraw():
+		return "Claim draw"
+	case g.OpponentOffersDraw():
+		return "Accept draw"
+	default:
+		return "Draw"
+	}
+}
+
 func getOpponentText(g *lichess.Game) string {
 	if g.OpponentOffersDraw() {
 		return "🤝 Draw offered"
 	}
+	if g.OpponentProposesTakeback() {
+		return "↩ Takeback proposed"
+	}
 	opponent := g.Opponent()
+	if opponent.Rating == 0 {
+		// offline opponents have no rating
+		return opponent.Username
+	}
 	return fmt.Sprintf("(%d) %s", opponent.Rating, opponent.Username)
 }
 
-func makeBtn(label string, action UIOutput, c chan UIOutput) *tview.Button {
-	btn := tview.NewButton(label).
-		SetSelectedFunc(func() { c <- action })
+func makeSeekBtn(preset SeekPreset, c chan lichess.SeekRequest) *tview.Button {
+	btn := tview.NewButton(preset.Label).
+		SetSelectedFunc(func() { c <- preset.SeekRequest() })
 
 	return btn
 }